@@ -280,6 +280,9 @@ const (
 	WorkflowStepType DefinitionType = "WorkflowStep"
 )
 
+// AllDefinitionTypes lists every DefinitionType, keep it in sync with the constants above
+var AllDefinitionTypes = []DefinitionType{ComponentType, TraitType, PolicyType, WorkflowStepType}
+
 // ApplicationTrait defines the trait of application
 type ApplicationTrait struct {
 	Type string `json:"type"`
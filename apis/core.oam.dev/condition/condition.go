@@ -134,6 +134,11 @@ func (s *ConditionedStatus) GetCondition(ct ConditionType) Condition {
 	return Condition{Type: ct, Status: corev1.ConditionUnknown}
 }
 
+// GetConditions returns all the conditions
+func (s *ConditionedStatus) GetConditions() []Condition {
+	return s.Conditions
+}
+
 // SetConditions sets the supplied conditions, replacing any existing conditions
 // of the same type. This is a no-op if all supplied conditions are identical,
 // ignoring the last transition time, to those already set.
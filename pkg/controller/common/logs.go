@@ -16,26 +16,21 @@ limitations under the License.
 
 package common
 
-import "k8s.io/klog/v2"
-
-// klog has multiple levels, you can set the log levels by klog.V()
-// Basic examples:
-//
-//	klog.V(1).Info("Prepare to repel boarders")
-//
-//	klog.V(2).ErrorS(err, "Initialization failed")
+import "github.com/oam-dev/kubevela/pkg/oam"
+
+// The klog levels are defined in pkg/oam so that packages the controllers depend on can share them
 const (
 	// LogInfo level is for most info logs, this is the default
 	// One should just call Info directly.
-	LogInfo klog.Level = iota
+	LogInfo = oam.LogInfo
 
 	// LogDebug is for more verbose logs
-	LogDebug
+	LogDebug = oam.LogDebug
 
 	// LogDebugWithContent is recommended if one wants to log with the content of the object,
 	// ie. http body, json/yaml file content
-	LogDebugWithContent
+	LogDebugWithContent = oam.LogDebugWithContent
 
 	// LogTrace is the most verbose log level, don't add anything after this
-	LogTrace = 100
+	LogTrace = oam.LogTrace
 )
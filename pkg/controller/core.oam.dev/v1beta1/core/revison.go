@@ -37,7 +37,6 @@ import (
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/condition"
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
-	"github.com/oam-dev/kubevela/pkg/controller/utils"
 	"github.com/oam-dev/kubevela/pkg/oam"
 	"github.com/oam-dev/kubevela/pkg/oam/util"
 )
@@ -183,7 +182,7 @@ func GatherRevisionInfo(def runtime.Object) (*v1beta1.DefinitionRevision, *commo
 		return nil, nil, fmt.Errorf("unsupported type %v", definition)
 	}
 
-	defHash, err := computeDefinitionRevisionHash(defRev)
+	defHash, err := util.ComputeDefinitionRevisionHash(defRev)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -191,34 +190,6 @@ func GatherRevisionInfo(def runtime.Object) (*v1beta1.DefinitionRevision, *commo
 	return defRev, LastRevision, nil
 }
 
-func computeDefinitionRevisionHash(defRev *v1beta1.DefinitionRevision) (string, error) {
-	var defHash string
-	var err error
-	switch defRev.Spec.DefinitionType {
-	case common.ComponentType:
-		defHash, err = utils.ComputeSpecHash(&defRev.Spec.ComponentDefinition.Spec)
-		if err != nil {
-			return defHash, err
-		}
-	case common.TraitType:
-		defHash, err = utils.ComputeSpecHash(&defRev.Spec.TraitDefinition.Spec)
-		if err != nil {
-			return defHash, err
-		}
-	case common.PolicyType:
-		defHash, err = utils.ComputeSpecHash(&defRev.Spec.PolicyDefinition.Spec)
-		if err != nil {
-			return defHash, err
-		}
-	case common.WorkflowStepType:
-		defHash, err = utils.ComputeSpecHash(&defRev.Spec.WorkflowStepDefinition.Spec)
-		if err != nil {
-			return defHash, err
-		}
-	}
-	return defHash, nil
-}
-
 func compareWithLastDefRevisionSpec(ctx context.Context, cli client.Client,
 	newDefRev *v1beta1.DefinitionRevision, lastRevision *common.Revision) (bool, error) {
 	if lastRevision == nil {
@@ -21,8 +21,6 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/mitchellh/hashstructure/v2"
-
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
 	"github.com/oam-dev/kubevela/pkg/oam/util"
 )
@@ -58,12 +56,4 @@ func ExtractRevision(revisionName string) (int, error) {
 }
 
 // ComputeSpecHash computes the hash value of a k8s resource spec
-func ComputeSpecHash(spec interface{}) (string, error) {
-	// compute a hash value of any resource spec
-	specHash, err := hashstructure.Hash(spec, hashstructure.FormatV2, nil)
-	if err != nil {
-		return "", err
-	}
-	specHashLabel := strconv.FormatUint(specHash, 16)
-	return specHashLabel, nil
-}
+var ComputeSpecHash = util.ComputeSpecHash
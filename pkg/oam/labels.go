@@ -57,6 +57,8 @@ const (
 	LabelPolicyDefinitionName = "policydefinition.oam.dev/name"
 	// LabelWorkflowStepDefinitionName records the name of WorkflowStepDefinition
 	LabelWorkflowStepDefinitionName = "workflowstepdefinition.oam.dev/name"
+	// LabelDefinitionAlias records the alias of the definition
+	LabelDefinitionAlias = "definition.oam.dev/alias"
 
 	// LabelControllerRevisionComponent indicate which component the revision belong to
 	LabelControllerRevisionComponent = "controller.oam.dev/component"
@@ -197,6 +199,9 @@ const (
 
 	// AnnotationSkipResume annotation indicates that the resource does not need to be resumed.
 	AnnotationSkipResume = "controller.core.oam.dev/skip-resume"
+
+	// AnnotationXDefinitionNamespace indicates the namespace of the x-definitions used by the application
+	AnnotationXDefinitionNamespace = "app.oam.dev/xdefinition-namespace"
 )
 
 const (
@@ -0,0 +1,327 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+)
+
+// DefaultDefinitionCacheTTL is the default time to live of the definitions cached by CachingDefinitionGetter
+const DefaultDefinitionCacheTTL = time.Minute
+
+// DefinitionGetter gets definition from two level namespace
+type DefinitionGetter interface {
+	GetDefinition(ctx context.Context, definition client.Object, definitionName string) error
+}
+
+type definitionGetter struct {
+	cli client.Reader
+}
+
+// GetDefinition get definition from two level namespace
+func (g *definitionGetter) GetDefinition(ctx context.Context, definition client.Object, definitionName string) error {
+	return GetDefinition(ctx, g.cli, definition, definitionName)
+}
+
+// NewDefinitionGetter create a DefinitionGetter which reads definitions through the client directly
+func NewDefinitionGetter(cli client.Reader) DefinitionGetter {
+	return &definitionGetter{cli: cli}
+}
+
+// DefinitionCacheOption is the option for CachingDefinitionGetter
+type DefinitionCacheOption func(*CachingDefinitionGetter)
+
+// WithDefinitionCacheTTL sets the time to live of the cached definitions
+func WithDefinitionCacheTTL(ttl time.Duration) DefinitionCacheOption {
+	return func(g *CachingDefinitionGetter) {
+		g.entries.ttl = ttl
+	}
+}
+
+// WithDefinitionCacheClock sets the clock used to expire the cached definitions
+func WithDefinitionCacheClock(clk clock.PassiveClock) DefinitionCacheOption {
+	return func(g *CachingDefinitionGetter) {
+		g.entries.clock = clk
+	}
+}
+
+// ttlCache is a map whose entries expire after the TTL. The expired entries are evicted when they are read, and all
+// of them are swept out by the writes at most once per TTL, so the entries never read again won't stay forever.
+type ttlCache[K comparable, V any] struct {
+	ttl   time.Duration
+	clock clock.PassiveClock
+
+	mu        sync.Mutex
+	entries   map[K]ttlCacheEntry[V]
+	nextSweep time.Time
+}
+
+type ttlCacheEntry[V any] struct {
+	value    V
+	expireAt time.Time
+}
+
+func newTTLCache[K comparable, V any](ttl time.Duration) *ttlCache[K, V] {
+	return &ttlCache[K, V]{ttl: ttl, clock: clock.RealClock{}, entries: map[K]ttlCacheEntry[V]{}}
+}
+
+func (c *ttlCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !c.clock.Now().Before(entry.expireAt) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache[K, V]) set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	if !now.Before(c.nextSweep) {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expireAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(c.ttl)
+	}
+	c.entries[key] = ttlCacheEntry[V]{value: value, expireAt: now.Add(c.ttl)}
+}
+
+func (c *ttlCache[K, V]) deleteFunc(del func(K, V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if del(k, entry.value) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *ttlCache[K, V]) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[K]ttlCacheEntry[V]{}
+}
+
+func (c *ttlCache[K, V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// definitionLookupKey identifies a lookup, the same definition name may be resolved to different
+// namespaces with different app namespace and x-definition namespace in context
+type definitionLookupKey struct {
+	kind                 string
+	name                 string
+	appNamespace         string
+	xDefinitionNamespace string
+}
+
+// definitionCacheKey identifies a resolved definition
+type definitionCacheKey struct {
+	name            string
+	namespace       string
+	resourceVersion string
+}
+
+type definitionCacheEntry struct {
+	key        definitionCacheKey
+	definition client.Object
+}
+
+// CachingDefinitionGetter is a DefinitionGetter which caches the resolved definitions in memory.
+// The cached definitions will be expired after the TTL or removed by Invalidate.
+type CachingDefinitionGetter struct {
+	cli     client.Reader
+	entries *ttlCache[definitionLookupKey, *definitionCacheEntry]
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewCachingDefinitionGetter create a CachingDefinitionGetter
+func NewCachingDefinitionGetter(cli client.Reader, opts ...DefinitionCacheOption) *CachingDefinitionGetter {
+	g := &CachingDefinitionGetter{
+		cli:     cli,
+		entries: newTTLCache[definitionLookupKey, *definitionCacheEntry](DefaultDefinitionCacheTTL),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// GetDefinition get definition from cache, if not found, get it from two level namespace and cache it
+func (g *CachingDefinitionGetter) GetDefinition(ctx context.Context, definition client.Object, definitionName string) error {
+	lookupKey := definitionLookupKey{
+		kind:                 fmt.Sprintf("%T", definition),
+		name:                 definitionName,
+		appNamespace:         GetDefinitionNamespaceWithCtx(ctx),
+		xDefinitionNamespace: GetXDefinitionNamespaceWithCtx(ctx),
+	}
+	if entry, ok := g.entries.get(lookupKey); ok {
+		g.hits.Add(1)
+		reflect.ValueOf(definition).Elem().Set(reflect.ValueOf(entry.definition.DeepCopyObject()).Elem())
+		return nil
+	}
+
+	g.misses.Add(1)
+	if err := GetDefinition(ctx, g.cli, definition, definitionName); err != nil {
+		return err
+	}
+	g.entries.set(lookupKey, &definitionCacheEntry{
+		key: definitionCacheKey{
+			name:            definition.GetName(),
+			namespace:       definition.GetNamespace(),
+			resourceVersion: definition.GetResourceVersion(),
+		},
+		definition: definition.DeepCopyObject().(client.Object),
+	})
+	return nil
+}
+
+// Invalidate removes the cached definitions resolved to the given object, it is a hook which can be called
+// when the definition is updated or deleted. The cached ones with the same resourceVersion will be kept.
+func (g *CachingDefinitionGetter) Invalidate(obj client.Object) {
+	g.entries.deleteFunc(func(_ definitionLookupKey, entry *definitionCacheEntry) bool {
+		return entry.key.name == obj.GetName() && entry.key.namespace == obj.GetNamespace() &&
+			(obj.GetResourceVersion() == "" || entry.key.resourceVersion != obj.GetResourceVersion())
+	})
+}
+
+// Flush removes all the cached definitions
+func (g *CachingDefinitionGetter) Flush() {
+	g.entries.flush()
+}
+
+// Len returns the number of the cached definitions, the expired ones not evicted yet are included
+func (g *CachingDefinitionGetter) Len() int {
+	return g.entries.len()
+}
+
+// Hits returns the count of cache hits
+func (g *CachingDefinitionGetter) Hits() int64 {
+	return g.hits.Load()
+}
+
+// Misses returns the count of cache misses
+func (g *CachingDefinitionGetter) Misses() int64 {
+	return g.misses.Load()
+}
+
+// DefaultDefinitionRevisionListCacheTTL is the default time to live of the revision lists cached by
+// DefinitionRevisionListCache
+const DefaultDefinitionRevisionListCacheTTL = 5 * time.Second
+
+// DefinitionRevisionListCacheOption is the option for DefinitionRevisionListCache
+type DefinitionRevisionListCacheOption func(*DefinitionRevisionListCache)
+
+// WithDefinitionRevisionListCacheTTL sets the time to live of the cached revision lists
+func WithDefinitionRevisionListCacheTTL(ttl time.Duration) DefinitionRevisionListCacheOption {
+	return func(c *DefinitionRevisionListCache) {
+		c.entries.ttl = ttl
+	}
+}
+
+// WithDefinitionRevisionListCacheClock sets the clock used to expire the cached revision lists
+func WithDefinitionRevisionListCacheClock(clk clock.PassiveClock) DefinitionRevisionListCacheOption {
+	return func(c *DefinitionRevisionListCache) {
+		c.entries.clock = clk
+	}
+}
+
+type definitionRevisionListKey struct {
+	namespace      string
+	definitionName string
+	definitionType common.DefinitionType
+}
+
+// DefinitionRevisionListCache caches the DefinitionRevision lists of the definitions used by
+// GetLatestDefinitionRevisionName, so the frequent auto-update resolutions don't list the revisions every time.
+// The lists may be stale until the TTL expires. It is injected by SetDefinitionRevisionListCacheInCtx.
+type DefinitionRevisionListCache struct {
+	entries *ttlCache[definitionRevisionListKey, *v1beta1.DefinitionRevisionList]
+}
+
+// NewDefinitionRevisionListCache create a DefinitionRevisionListCache
+func NewDefinitionRevisionListCache(opts ...DefinitionRevisionListCacheOption) *DefinitionRevisionListCache {
+	c := &DefinitionRevisionListCache{
+		entries: newTTLCache[definitionRevisionListKey, *v1beta1.DefinitionRevisionList](DefaultDefinitionRevisionListCacheTTL),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *DefinitionRevisionListCache) list(ctx context.Context, cli client.Reader, ns, definitionName string,
+	definitionType common.DefinitionType) (*v1beta1.DefinitionRevisionList, error) {
+	key := definitionRevisionListKey{namespace: ns, definitionName: definitionName, definitionType: definitionType}
+	if cached, ok := c.entries.get(key); ok {
+		return cached.DeepCopy(), nil
+	}
+	list, err := listAllRevisionsForDefinitionName(ctx, cli, ns, definitionName, definitionType)
+	if err != nil {
+		return list, err
+	}
+	c.entries.set(key, list.DeepCopy())
+	return list, nil
+}
+
+// Flush removes all the cached revision lists
+func (c *DefinitionRevisionListCache) Flush() {
+	c.entries.flush()
+}
+
+// Len returns the number of the cached revision lists, the expired ones not evicted yet are included
+func (c *DefinitionRevisionListCache) Len() int {
+	return c.entries.len()
+}
+
+type definitionRevisionListCacheContextKey struct{}
+
+// SetDefinitionRevisionListCacheInCtx set the DefinitionRevisionListCache in context, which will be used by
+// GetLatestDefinitionRevisionName to list the revisions
+func SetDefinitionRevisionListCacheInCtx(ctx context.Context, cache *DefinitionRevisionListCache) context.Context {
+	return context.WithValue(ctx, definitionRevisionListCacheContextKey{}, cache)
+}
+
+func getDefinitionRevisionListCacheWithCtx(ctx context.Context) *DefinitionRevisionListCache {
+	cache, _ := ctx.Value(definitionRevisionListCacheContextKey{}).(*DefinitionRevisionListCache)
+	return cache
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+func TestCachingDefinitionGetter(t *testing.T) {
+	sysTraitDefinition := v1beta1.TraitDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "mockdefinition",
+			Namespace:       "vela-system",
+			ResourceVersion: "1",
+		},
+	}
+	var getCount int
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		getCount++
+		if td, ok := obj.(*v1beta1.TraitDefinition); ok && key.Namespace == "vela-system" {
+			*td = sysTraitDefinition
+			return nil
+		}
+		return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "traitDefinition"}, key.Name)
+	}}
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	getter := util.NewCachingDefinitionGetter(&cli, util.WithDefinitionCacheTTL(time.Minute), util.WithDefinitionCacheClock(clk))
+	ctx := util.SetNamespaceInCtx(context.Background(), "vela-app")
+
+	td := new(v1beta1.TraitDefinition)
+	assert.NoError(t, getter.GetDefinition(ctx, td, "mockdefinition"))
+	assert.Equal(t, sysTraitDefinition, *td)
+	assert.Equal(t, int64(0), getter.Hits())
+	assert.Equal(t, int64(1), getter.Misses())
+	lookupCount := getCount
+
+	// hit the cache and the cached definition should not be modified by the caller
+	td.Spec.Version = "modified"
+	cached := new(v1beta1.TraitDefinition)
+	assert.NoError(t, getter.GetDefinition(ctx, cached, "mockdefinition"))
+	assert.Equal(t, sysTraitDefinition, *cached)
+	assert.Equal(t, lookupCount, getCount)
+	assert.Equal(t, int64(1), getter.Hits())
+
+	// definitions of other types are cached separately
+	cd := new(v1beta1.ComponentDefinition)
+	assert.Error(t, getter.GetDefinition(ctx, cd, "mockdefinition"))
+	assert.Equal(t, int64(2), getter.Misses())
+
+	// invalidation with the same resourceVersion keeps the cache
+	getter.Invalidate(sysTraitDefinition.DeepCopy())
+	assert.NoError(t, getter.GetDefinition(ctx, cached, "mockdefinition"))
+	assert.Equal(t, int64(2), getter.Hits())
+
+	// invalidation with a new resourceVersion removes the cache
+	sysTraitDefinition.ResourceVersion = "2"
+	getter.Invalidate(sysTraitDefinition.DeepCopy())
+	assert.NoError(t, getter.GetDefinition(ctx, cached, "mockdefinition"))
+	assert.Equal(t, "2", cached.ResourceVersion)
+	assert.Equal(t, int64(3), getter.Misses())
+
+	// the cache is expired after TTL
+	clk.SetTime(clk.Now().Add(2 * time.Minute))
+	assert.NoError(t, getter.GetDefinition(ctx, cached, "mockdefinition"))
+	assert.Equal(t, int64(4), getter.Misses())
+	assert.Equal(t, 1, getter.Len())
+
+	getter.Flush()
+	assert.NoError(t, getter.GetDefinition(ctx, cached, "mockdefinition"))
+	assert.Equal(t, int64(5), getter.Misses())
+	assert.Equal(t, int64(2), getter.Hits())
+
+	var direct util.DefinitionGetter = util.NewDefinitionGetter(&cli)
+	assert.NoError(t, direct.GetDefinition(ctx, td, "mockdefinition"))
+	assert.Equal(t, sysTraitDefinition, *td)
+}
+
+func TestCachingDefinitionGetterEviction(t *testing.T) {
+	deleted := map[string]bool{}
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		if deleted[key.Name] {
+			return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "traitDefinition"}, key.Name)
+		}
+		obj.SetName(key.Name)
+		obj.SetNamespace(key.Namespace)
+		return nil
+	}}
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	getter := util.NewCachingDefinitionGetter(&cli, util.WithDefinitionCacheTTL(time.Minute), util.WithDefinitionCacheClock(clk))
+	ctx := context.Background()
+
+	assert.NoError(t, getter.GetDefinition(ctx, new(v1beta1.TraitDefinition), "first"))
+	assert.NoError(t, getter.GetDefinition(ctx, new(v1beta1.TraitDefinition), "second"))
+	assert.Equal(t, 2, getter.Len())
+
+	// the expired definition is evicted when it is read
+	clk.SetTime(clk.Now().Add(2 * time.Minute))
+	deleted["first"] = true
+	assert.True(t, apierrors.IsNotFound(getter.GetDefinition(ctx, new(v1beta1.TraitDefinition), "first")))
+	assert.Equal(t, 1, getter.Len())
+
+	// the expired definitions never read again are swept out by the writes
+	assert.NoError(t, getter.GetDefinition(ctx, new(v1beta1.TraitDefinition), "third"))
+	assert.Equal(t, 1, getter.Len())
+	assert.Equal(t, int64(4), getter.Misses())
+	assert.Equal(t, int64(0), getter.Hits())
+}
+
+func TestDefinitionRevisionListCache(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "v1.0.0", "v1.2.0")
+	var listCount int
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		listCount++
+		listOpts := &client.ListOptions{}
+		listOpts.ApplyOptions(opts)
+		if listOpts.Namespace == "vela-system" {
+			list.(*v1beta1.DefinitionRevisionList).Items = revisions
+		}
+		return nil
+	}}
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	cache := util.NewDefinitionRevisionListCache(util.WithDefinitionRevisionListCacheTTL(5*time.Second), util.WithDefinitionRevisionListCacheClock(clk))
+	ctx := util.SetDefinitionRevisionListCacheInCtx(context.Background(), cache)
+
+	name, err := util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", "worker-v1", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.2.0", name)
+	assert.Equal(t, 1, listCount)
+
+	// the revision list is cached within the TTL
+	revisions = newComponentDefRevisions("worker", "v1.0.0", "v1.2.0", "v1.3.0")
+	clk.SetTime(clk.Now().Add(3 * time.Second))
+	name, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", "worker-v1", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.2.0", name)
+	assert.Equal(t, 1, listCount)
+
+	// the cache is keyed by the definition name
+	_, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "task", "task-v1", common.ComponentType)
+	assert.Error(t, err)
+	assert.Equal(t, 2, listCount)
+
+	clk.SetTime(clk.Now().Add(3 * time.Second))
+	name, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", "worker-v1", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.3.0", name)
+	assert.Equal(t, 3, listCount)
+
+	// the expired lists never read again are swept out by the writes
+	assert.Equal(t, 2, cache.Len())
+	clk.SetTime(clk.Now().Add(10 * time.Second))
+	_, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "task", "task-v1", common.ComponentType)
+	assert.Error(t, err)
+	assert.Equal(t, 1, cache.Len())
+	assert.Equal(t, 4, listCount)
+
+	cache.Flush()
+	assert.Equal(t, 0, cache.Len())
+	_, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", "worker-v1", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, listCount)
+
+	// not cached without the cache in context
+	_, err = util.GetLatestDefinitionRevisionName(context.Background(), &cli, "worker", "worker-v1", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, listCount)
+}
@@ -0,0 +1,405 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// DefaultDefinitionRevisionPageSize is the default number of DefinitionRevisions fetched by each List call
+const DefaultDefinitionRevisionPageSize int64 = 100
+
+// ListDefinitionRevisionsOptions is the options for ListDefinitionRevisions
+type ListDefinitionRevisionsOptions struct {
+	// PageSize is the number of revisions fetched by each List call, DefaultDefinitionRevisionPageSize will be used if not set
+	PageSize int64
+	// MaxRevisions caps how many newest revisions are returned, 0 means no limit
+	MaxRevisions int
+}
+
+// DefinitionRevisionSelector returns the label selector of the DefinitionRevisions of the definition, the label key
+// is looked up from DefinitionKindToNameLabel by the definition type. An error is returned if the definition type is
+// not supported or the definition name is not a valid label value.
+func DefinitionRevisionSelector(definitionName string, definitionType common.DefinitionType) (labels.Selector, error) {
+	labelKey, ok := DefinitionKindToNameLabel[definitionType]
+	if !ok {
+		return nil, errors.Errorf("unsupported definition type %q for DefinitionRevision", definitionType)
+	}
+	selector, err := labels.ValidatedSelectorFromSet(labels.Set{labelKey: definitionName})
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid definition name %q for DefinitionRevision selector", definitionName)
+	}
+	return selector, nil
+}
+
+// ListDefinitionRevisions lists the DefinitionRevisions of the definition in the namespace page by page.
+// The revisions are sorted by semver in descending order, only the newest MaxRevisions ones are kept in memory.
+func ListDefinitionRevisions(ctx context.Context, cli client.Reader, namespace, definitionName string,
+	definitionType common.DefinitionType, opts ListDefinitionRevisionsOptions) ([]v1beta1.DefinitionRevision, error) {
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultDefinitionRevisionPageSize
+	}
+	selector, err := DefinitionRevisionSelector(definitionName, definitionType)
+	if err != nil {
+		return nil, err
+	}
+	var revisions []v1beta1.DefinitionRevision
+	continueToken := ""
+	for {
+		revisionList := &v1beta1.DefinitionRevisionList{}
+		listOptions := []client.ListOption{
+			client.InNamespace(namespace),
+			client.MatchingLabelsSelector{Selector: selector},
+			client.Limit(pageSize),
+		}
+		if continueToken != "" {
+			listOptions = append(listOptions, client.Continue(continueToken))
+		}
+		if err := cli.List(ctx, revisionList, listOptions...); err != nil {
+			return nil, err
+		}
+		for _, revision := range revisionList.Items {
+			if definitionType != "" && revision.Spec.DefinitionType != "" && definitionType != revision.Spec.DefinitionType {
+				continue
+			}
+			revisions = append(revisions, revision)
+		}
+		SortDefinitionRevisions(revisions, definitionName)
+		if opts.MaxRevisions > 0 && len(revisions) > opts.MaxRevisions {
+			revisions = revisions[:opts.MaxRevisions]
+		}
+		if continueToken = revisionList.GetContinue(); continueToken == "" {
+			break
+		}
+	}
+	return revisions, nil
+}
+
+// NextDefinitionRevisionNumber computes the number of the next DefinitionRevision of the definition in the namespace.
+// The numbers are parsed from the revision names like worker-v3 in the same way as ExtractRevisionNum, and the max
+// number plus one is returned, so the gaps are never reused. It returns 1 if there is no revision yet. The revisions
+// whose name cannot be parsed are skipped with a warning.
+func NextDefinitionRevisionNumber(ctx context.Context, cli client.Reader, definitionName string,
+	definitionType common.DefinitionType, ns string) (int64, error) {
+	revisions, err := ListDefinitionRevisions(ctx, cli, ns, definitionName, definitionType, ListDefinitionRevisionsOptions{})
+	if err != nil {
+		return 0, err
+	}
+	var maxNum int64
+	for _, revision := range revisions {
+		num, err := ExtractRevisionNum(revision.Name, "-")
+		if err != nil {
+			klog.Warningf("skip the DefinitionRevision %s/%s with malformed name: %v", revision.Namespace, revision.Name, err)
+			continue
+		}
+		maxNum = max(maxNum, int64(num))
+	}
+	return maxNum + 1, nil
+}
+
+// SortDefinitionRevisions sorts the DefinitionRevisions by the semver in their names in descending order,
+// e.g. worker-v1.3.0 is ahead of worker-v1.2.4. The revisions without semver are put at last and
+// sorted by the revision number in descending order.
+func SortDefinitionRevisions(revisions []v1beta1.DefinitionRevision, definitionName string) {
+	versionOf := func(revision v1beta1.DefinitionRevision) *semver.Version {
+		v, err := semver.NewVersion(strings.TrimPrefix(revision.Name, definitionName+"-"))
+		if err != nil {
+			return nil
+		}
+		return v
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		vi, vj := versionOf(revisions[i]), versionOf(revisions[j])
+		switch {
+		case vi != nil && vj != nil && !vi.Equal(vj):
+			return vi.GreaterThan(vj)
+		case vi != nil && vj == nil:
+			return true
+		case vi == nil && vj != nil:
+			return false
+		default:
+			return revisions[i].Spec.Revision > revisions[j].Spec.Revision
+		}
+	})
+}
+
+// GetDefinitionRevisionByHash gets the DefinitionRevision of the definition in the x-definition namespace whose
+// revision hash equals the given hash, it can be used to check whether an identical revision already exists before
+// creating a new one. A NotFound error will be returned if no revision matches.
+func GetDefinitionRevisionByHash(ctx context.Context, cli client.Reader, definitionName string, definitionType common.DefinitionType, hash string) (*v1beta1.DefinitionRevision, error) {
+	revisions, err := ListDefinitionRevisions(ctx, cli, GetXDefinitionNamespaceWithCtx(ctx), definitionName, definitionType, ListDefinitionRevisionsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range revisions {
+		if revisions[i].Spec.RevisionHash == hash {
+			return &revisions[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(v1beta1.SchemeGroupVersion.WithResource("definitionrevisions").GroupResource(), fmt.Sprintf("%s(hash=%s)", definitionName, hash))
+}
+
+// GetDefinitionRevisionByLabel gets the DefinitionRevision of the definition in the x-definition namespace which has
+// the label, e.g. `release: ga`, it is a selection path for the revisions not versioned by semver. The one with the
+// highest revision number is returned if multiple revisions match. A NotFound error will be returned if no revision matches.
+func GetDefinitionRevisionByLabel(ctx context.Context, cli client.Reader, definitionName string, definitionType common.DefinitionType,
+	labelKey, labelValue string) (*v1beta1.DefinitionRevision, error) {
+	revisions, err := ListDefinitionRevisions(ctx, cli, GetXDefinitionNamespaceWithCtx(ctx), definitionName, definitionType, ListDefinitionRevisionsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var matched *v1beta1.DefinitionRevision
+	for i := range revisions {
+		if value, ok := revisions[i].Labels[labelKey]; !ok || value != labelValue {
+			continue
+		}
+		if matched == nil || revisions[i].Spec.Revision > matched.Spec.Revision {
+			matched = &revisions[i]
+		}
+	}
+	if matched == nil {
+		return nil, apierrors.NewNotFound(v1beta1.SchemeGroupVersion.WithResource("definitionrevisions").GroupResource(),
+			fmt.Sprintf("%s(%s=%s)", definitionName, labelKey, labelValue))
+	}
+	return matched, nil
+}
+
+// BuildDefinitionRevisionMeta computes the name and the spec hash of the DefinitionRevision for the given revision
+// number of the definition. The name follows the same rules as ConvertDefinitionRevName, e.g. revision 3 of worker
+// will be named worker-v3, and the hash is computed by ComputeDefinitionRevisionHash, so the reconciler and the CLI
+// will always agree on them. The unstructured definition is converted into the typed one by its kind before hashing.
+func BuildDefinitionRevisionMeta(def client.Object, revisionNumber int) (name string, hash string, err error) {
+	if revisionNumber <= 0 {
+		return "", "", fmt.Errorf("invalid revision number %d for definition %s", revisionNumber, def.GetName())
+	}
+	if u, ok := def.(*unstructured.Unstructured); ok {
+		if def, err = convertUnstructuredDefinition(u); err != nil {
+			return "", "", err
+		}
+	}
+	defRev := &v1beta1.DefinitionRevision{}
+	switch d := def.(type) {
+	case *v1beta1.ComponentDefinition:
+		defRev.Spec.DefinitionType = common.ComponentType
+		defRev.Spec.ComponentDefinition = *d
+	case *v1beta1.TraitDefinition:
+		defRev.Spec.DefinitionType = common.TraitType
+		defRev.Spec.TraitDefinition = *d
+	case *v1beta1.PolicyDefinition:
+		defRev.Spec.DefinitionType = common.PolicyType
+		defRev.Spec.PolicyDefinition = *d
+	case *v1beta1.WorkflowStepDefinition:
+		defRev.Spec.DefinitionType = common.WorkflowStepType
+		defRev.Spec.WorkflowStepDefinition = *d
+	default:
+		return "", "", fmt.Errorf("invalid definition type for %v", def.GetName())
+	}
+	name, err = ConvertDefinitionRevName(fmt.Sprintf("%s@v%d", def.GetName(), revisionNumber))
+	if err != nil {
+		return "", "", err
+	}
+	hash, err = ComputeDefinitionRevisionHash(defRev)
+	if err != nil {
+		return "", "", err
+	}
+	return name, hash, nil
+}
+
+func convertUnstructuredDefinition(u *unstructured.Unstructured) (client.Object, error) {
+	var def client.Object
+	switch u.GetKind() {
+	case v1beta1.ComponentDefinitionKind:
+		def = &v1beta1.ComponentDefinition{}
+	case v1beta1.TraitDefinitionKind:
+		def = &v1beta1.TraitDefinition{}
+	case v1beta1.PolicyDefinitionKind:
+		def = &v1beta1.PolicyDefinition{}
+	case v1beta1.WorkflowStepDefinitionKind:
+		def = &v1beta1.WorkflowStepDefinition{}
+	default:
+		return nil, fmt.Errorf("invalid definition kind %q for %v", u.GetKind(), u.GetName())
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, def); err != nil {
+		return nil, errors.Wrapf(err, "failed to convert definition %s", u.GetName())
+	}
+	return def, nil
+}
+
+// ComputeDefinitionRevisionHash computes the hash of the definition spec held by the DefinitionRevision, it is the
+// RevisionHash recorded by the definition controllers.
+func ComputeDefinitionRevisionHash(defRev *v1beta1.DefinitionRevision) (string, error) {
+	switch defRev.Spec.DefinitionType {
+	case common.ComponentType:
+		return ComputeSpecHash(&defRev.Spec.ComponentDefinition.Spec)
+	case common.TraitType:
+		return ComputeSpecHash(&defRev.Spec.TraitDefinition.Spec)
+	case common.PolicyType:
+		return ComputeSpecHash(&defRev.Spec.PolicyDefinition.Spec)
+	case common.WorkflowStepType:
+		return ComputeSpecHash(&defRev.Spec.WorkflowStepDefinition.Spec)
+	}
+	return "", nil
+}
+
+// PruneDefinitionRevisions deletes the old DefinitionRevisions of the definition in the x-definition namespace,
+// only the newest `keep` revisions sorted by semver are retained, like the revisionHistoryLimit of Deployment.
+// The newest revision and the latest revision recorded in the status of the definition are always kept, so `keep`
+// less than 1 is regarded as 1, and a negative `keep` is rejected. The revisions referenced by the live Applications
+// will never be deleted. It returns the deleted revision names.
+func PruneDefinitionRevisions(ctx context.Context, cli client.Client, definitionName string, definitionType common.DefinitionType, keep int) ([]string, error) {
+	if keep < 0 {
+		return nil, errors.Errorf("invalid number of revisions to keep: %d", keep)
+	}
+	if keep < 1 {
+		keep = 1
+	}
+	revisions, err := ListDefinitionRevisions(ctx, cli, GetXDefinitionNamespaceWithCtx(ctx), definitionName, definitionType, ListDefinitionRevisionsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) <= keep {
+		return nil, nil
+	}
+	referenced, err := getReferencedDefinitionRevisions(ctx, cli, definitionName, definitionType, revisions)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := getLatestRevisionNameOfDefinition(ctx, cli, definitionName, definitionType)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for i := keep; i < len(revisions); i++ {
+		if referenced[revisions[i].Name] || revisions[i].Name == latest {
+			continue
+		}
+		if err := cli.Delete(ctx, &revisions[i]); err != nil && !apierrors.IsNotFound(err) {
+			return deleted, errors.Wrapf(err, "failed to delete DefinitionRevision %s", revisions[i].Name)
+		}
+		deleted = append(deleted, revisions[i].Name)
+	}
+	return deleted, nil
+}
+
+// getLatestRevisionNameOfDefinition returns the latest revision recorded in the status of the definition in the
+// x-definition namespace, it's empty if the definition is not found
+func getLatestRevisionNameOfDefinition(ctx context.Context, cli client.Reader, definitionName string, definitionType common.DefinitionType) (string, error) {
+	var def client.Object
+	switch definitionType {
+	case common.ComponentType:
+		def = &v1beta1.ComponentDefinition{}
+	case common.TraitType:
+		def = &v1beta1.TraitDefinition{}
+	case common.PolicyType:
+		def = &v1beta1.PolicyDefinition{}
+	case common.WorkflowStepType:
+		def = &v1beta1.WorkflowStepDefinition{}
+	default:
+		return "", nil
+	}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: GetXDefinitionNamespaceWithCtx(ctx), Name: definitionName}, def); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "failed to get definition %s", definitionName)
+	}
+	return latestRevisionNameOfDefinition(def), nil
+}
+
+func getReferencedDefinitionRevisions(ctx context.Context, cli client.Reader, definitionName string,
+	definitionType common.DefinitionType, revisions []v1beta1.DefinitionRevision) (map[string]bool, error) {
+	apps := &v1beta1.ApplicationList{}
+	if err := cli.List(ctx, apps); err != nil {
+		return nil, errors.Wrap(err, "failed to list applications")
+	}
+	revisionList := &v1beta1.DefinitionRevisionList{Items: revisions}
+	referenced := map[string]bool{}
+	for _, app := range apps.Items {
+		if app.DeletionTimestamp != nil {
+			continue
+		}
+		for _, typ := range getDefinitionTypesInApplication(&app, definitionType) {
+			defVersion, err := ParseDefinitionVersion(typ)
+			if err != nil || defVersion.Name != definitionName {
+				continue
+			}
+			revisionName := defVersion.RevisionName
+			switch {
+			case defVersion.Type == DefinitionVersionConstraint:
+				revisionName, _ = getMatchingDefinitionRevision(defVersion.Version, definitionName, revisionList, definitionType)
+			case defVersion.Type == DefinitionVersionExact && app.GetAnnotations()[oam.AnnotationAutoUpdate] == "true":
+				revisionName, _ = getMatchingDefinitionRevision(defVersion.RevisionName, definitionName, revisionList, definitionType)
+			case defVersion.Type != DefinitionVersionExact:
+				continue
+			}
+			if revisionName != "" {
+				referenced[revisionName] = true
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// getDefinitionTypesInApplication returns the types of the given definition type used in the Application
+func getDefinitionTypesInApplication(app *v1beta1.Application, definitionType common.DefinitionType) []string {
+	var types []string
+	switch definitionType {
+	case common.ComponentType:
+		for _, comp := range app.Spec.Components {
+			types = append(types, comp.Type)
+		}
+	case common.TraitType:
+		for _, comp := range app.Spec.Components {
+			for _, trait := range comp.Traits {
+				types = append(types, trait.Type)
+			}
+		}
+	case common.PolicyType:
+		for _, policy := range app.Spec.Policies {
+			types = append(types, policy.Type)
+		}
+	case common.WorkflowStepType:
+		if app.Spec.Workflow == nil {
+			break
+		}
+		for _, step := range app.Spec.Workflow.Steps {
+			types = append(types, step.Type)
+			for _, subStep := range step.SubSteps {
+				types = append(types, subStep.Type)
+			}
+		}
+	default:
+	}
+	return types
+}
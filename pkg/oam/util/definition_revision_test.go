@@ -0,0 +1,364 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1beta1/core"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+// newPagedRevisionClient returns a client which lists the given revisions page by page
+func newPagedRevisionClient(revisions []v1beta1.DefinitionRevision, listCount *int) *test.MockClient {
+	return &test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		*listCount++
+		listOpts := &client.ListOptions{}
+		listOpts.ApplyOptions(opts)
+		var matched []v1beta1.DefinitionRevision
+		for _, revision := range revisions {
+			if listOpts.Namespace != "" && revision.Namespace != listOpts.Namespace {
+				continue
+			}
+			if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(revision.Labels)) {
+				continue
+			}
+			matched = append(matched, revision)
+		}
+		start := 0
+		if listOpts.Continue != "" {
+			start, _ = strconv.Atoi(listOpts.Continue)
+		}
+		end := len(matched)
+		if listOpts.Limit > 0 && start+int(listOpts.Limit) < end {
+			end = start + int(listOpts.Limit)
+			list.SetContinue(strconv.Itoa(end))
+		}
+		list.(*v1beta1.DefinitionRevisionList).Items = matched[start:end]
+		return nil
+	}}
+}
+
+func newComponentDefRevisions(definitionName string, versions ...string) []v1beta1.DefinitionRevision {
+	var revisions []v1beta1.DefinitionRevision
+	for i, version := range versions {
+		revisions = append(revisions, v1beta1.DefinitionRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      definitionName + "-" + version,
+				Namespace: "vela-system",
+				Labels:    map[string]string{oam.LabelComponentDefinitionName: definitionName},
+			},
+			Spec: v1beta1.DefinitionRevisionSpec{Revision: int64(i + 1), DefinitionType: common.ComponentType},
+		})
+	}
+	return revisions
+}
+
+func TestListDefinitionRevisions(t *testing.T) {
+	var versions []string
+	// seed the revisions out of order
+	for i := 25; i > 0; i -= 2 {
+		versions = append(versions, fmt.Sprintf("v%d", i))
+	}
+	for i := 2; i <= 25; i += 2 {
+		versions = append(versions, fmt.Sprintf("v%d", i))
+	}
+	revisions := newComponentDefRevisions("worker", versions...)
+	revisions = append(revisions, newComponentDefRevisions("other", "v100")...)
+	var listCount int
+	cli := newPagedRevisionClient(revisions, &listCount)
+	ctx := context.Background()
+
+	got, err := util.ListDefinitionRevisions(ctx, cli, "vela-system", "worker", common.ComponentType, util.ListDefinitionRevisionsOptions{PageSize: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, listCount)
+	assert.Equal(t, 25, len(got))
+	for i, revision := range got {
+		assert.Equal(t, fmt.Sprintf("worker-v%d", 25-i), revision.Name)
+	}
+
+	listCount = 0
+	got, err = util.ListDefinitionRevisions(ctx, cli, "vela-system", "worker", common.ComponentType, util.ListDefinitionRevisionsOptions{PageSize: 10, MaxRevisions: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, listCount)
+	assert.Equal(t, []string{"worker-v25", "worker-v24", "worker-v23"}, []string{got[0].Name, got[1].Name, got[2].Name})
+	assert.Equal(t, 3, len(got))
+
+	got, err = util.ListDefinitionRevisions(ctx, cli, "default", "worker", common.ComponentType, util.ListDefinitionRevisionsOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(got))
+}
+
+func TestSortDefinitionRevisions(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "v1.2.0", "v1.10.0", "custom", "v1.3.0", "v1.2.4")
+	util.SortDefinitionRevisions(revisions, "worker")
+	var names []string
+	for _, revision := range revisions {
+		names = append(names, revision.Name)
+	}
+	assert.Equal(t, []string{"worker-v1.10.0", "worker-v1.3.0", "worker-v1.2.4", "worker-v1.2.0", "worker-custom"}, names)
+}
+
+func TestGetDefinitionRevisionByHash(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "v1", "v2", "v3")
+	for i := range revisions {
+		revisions[i].Spec.RevisionHash = fmt.Sprintf("hash-%d", i+1)
+	}
+	var listCount int
+	cli := newPagedRevisionClient(revisions, &listCount)
+	ctx := context.Background()
+
+	revision, err := util.GetDefinitionRevisionByHash(ctx, cli, "worker", common.ComponentType, "hash-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v2", revision.Name)
+
+	_, err = util.GetDefinitionRevisionByHash(ctx, cli, "worker", common.ComponentType, "hash-4")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	_, err = util.GetDefinitionRevisionByHash(ctx, cli, "webservice", common.ComponentType, "hash-1")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestPruneDefinitionRevisions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1beta1.AddToScheme(scheme))
+	var objs []client.Object
+	for _, revision := range newComponentDefRevisions("worker", "v1", "v2", "v3", "v4", "v5", "v6", "v7") {
+		objs = append(objs, revision.DeepCopy())
+	}
+	objs = append(objs, &v1beta1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "pinned", Namespace: "default"},
+		Spec: v1beta1.ApplicationSpec{Components: []common.ApplicationComponent{
+			{Name: "a", Type: "worker@v2"},
+			{Name: "b", Type: "webservice@v1"},
+		}},
+	}, &v1beta1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "constraint", Namespace: "app"},
+		Spec: v1beta1.ApplicationSpec{Components: []common.ApplicationComponent{
+			{Name: "a", Type: "worker@<4"},
+		}},
+	})
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	ctx := context.Background()
+
+	deleted, err := util.PruneDefinitionRevisions(ctx, cli, "worker", common.ComponentType, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"worker-v4", "worker-v1"}, deleted)
+
+	remaining := &v1beta1.DefinitionRevisionList{}
+	assert.NoError(t, cli.List(ctx, remaining))
+	var names []string
+	for _, revision := range remaining.Items {
+		names = append(names, revision.Name)
+	}
+	assert.ElementsMatch(t, []string{"worker-v2", "worker-v3", "worker-v5", "worker-v6", "worker-v7"}, names)
+
+	deleted, err = util.PruneDefinitionRevisions(ctx, cli, "worker", common.ComponentType, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, deleted)
+
+	// the newest revision and the latest revision of the definition survive keep=0
+	assert.NoError(t, cli.Create(ctx, &v1beta1.ComponentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: oam.SystemDefinitionNamespace},
+		Status:     v1beta1.ComponentDefinitionStatus{LatestRevision: &common.Revision{Name: "worker-v5", Revision: 5}},
+	}))
+	deleted, err = util.PruneDefinitionRevisions(ctx, cli, "worker", common.ComponentType, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"worker-v6"}, deleted)
+	remaining = &v1beta1.DefinitionRevisionList{}
+	assert.NoError(t, cli.List(ctx, remaining))
+	names = nil
+	for _, revision := range remaining.Items {
+		names = append(names, revision.Name)
+	}
+	assert.ElementsMatch(t, []string{"worker-v2", "worker-v3", "worker-v5", "worker-v7"}, names)
+
+	_, err = util.PruneDefinitionRevisions(ctx, cli, "worker", common.ComponentType, -1)
+	assert.Error(t, err)
+}
+
+func TestBuildDefinitionRevisionMeta(t *testing.T) {
+	def := &v1beta1.TraitDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "scaler", Namespace: "vela-system"},
+		Spec:       v1beta1.TraitDefinitionSpec{PodDisruptive: true},
+	}
+	name, hash, err := util.BuildDefinitionRevisionMeta(def, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "scaler-v3", name)
+	assert.NotEmpty(t, hash)
+
+	// the hash only depends on the spec
+	relabeled := def.DeepCopy()
+	relabeled.SetLabels(map[string]string{"foo": "bar"})
+	_, relabeledHash, err := util.BuildDefinitionRevisionMeta(relabeled, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, relabeledHash)
+
+	changed := def.DeepCopy()
+	changed.Spec.PodDisruptive = false
+	_, changedHash, err := util.BuildDefinitionRevisionMeta(changed, 3)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changedHash)
+
+	// the name must be convertible back by ConvertDefinitionRevName
+	revName, err := util.ConvertDefinitionRevName("scaler@v3")
+	assert.NoError(t, err)
+	assert.Equal(t, revName, name)
+
+	// the hash is the same as the one recorded by the definition controller
+	defRev, _, err := core.GatherRevisionInfo(def)
+	assert.NoError(t, err)
+	assert.Equal(t, defRev.Spec.RevisionHash, hash)
+	compDef := &v1beta1.ComponentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "vela-system"},
+		Spec: v1beta1.ComponentDefinitionSpec{
+			Workload:           common.WorkloadTypeDescriptor{Type: "deployments.apps"},
+			ChildResourceKinds: []common.ChildResourceKind{{APIVersion: "v1", Kind: "Service"}},
+		},
+	}
+	name, compHash, err := util.BuildDefinitionRevisionMeta(compDef, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v2", name)
+	defRev, _, err = core.GatherRevisionInfo(compDef)
+	assert.NoError(t, err)
+	assert.Equal(t, defRev.Spec.RevisionHash, compHash)
+
+	// the unstructured definition is hashed like the typed one of its kind
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": v1beta1.SchemeGroupVersion.String(),
+		"kind":       v1beta1.TraitDefinitionKind,
+		"metadata":   map[string]interface{}{"name": "scaler"},
+		"spec":       map[string]interface{}{"podDisruptive": true},
+	}}
+	name, unstructuredHash, err := util.BuildDefinitionRevisionMeta(u, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "scaler-v1", name)
+	assert.Equal(t, hash, unstructuredHash)
+	u.SetKind("Application")
+	_, _, err = util.BuildDefinitionRevisionMeta(u, 1)
+	assert.Error(t, err)
+
+	_, _, err = util.BuildDefinitionRevisionMeta(def, 0)
+	assert.Error(t, err)
+	_, _, err = util.BuildDefinitionRevisionMeta(&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "bad name"}}, 1)
+	assert.True(t, util.IsInvalidRevisionName(err))
+	_, _, err = util.BuildDefinitionRevisionMeta(&v1beta1.Application{}, 1)
+	assert.Error(t, err)
+}
+
+func TestNextDefinitionRevisionNumber(t *testing.T) {
+	cases := map[string]struct {
+		versions []string
+		want     int64
+	}{
+		"empty":      {want: 1},
+		"sequential": {versions: []string{"v1", "v2", "v3"}, want: 4},
+		"gaps":       {versions: []string{"v1", "v7", "v3"}, want: 8},
+		"malformed":  {versions: []string{"v2", "v1.2.0", "vx"}, want: 3},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var listCount int
+			cli := newPagedRevisionClient(newComponentDefRevisions("worker", tc.versions...), &listCount)
+			num, err := util.NextDefinitionRevisionNumber(context.Background(), cli, "worker", common.ComponentType, "vela-system")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, num)
+		})
+	}
+
+	cli := &test.MockClient{MockList: test.NewMockListFn(fmt.Errorf("list failed"))}
+	_, err := util.NextDefinitionRevisionNumber(context.Background(), cli, "worker", common.ComponentType, "vela-system")
+	assert.Error(t, err)
+}
+
+func TestGetDefinitionRevisionByLabel(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "ga-1", "beta-2", "ga-3", "ga-old")
+	revisions[0].Labels["release"] = "ga"
+	revisions[1].Labels["release"] = "beta"
+	revisions[2].Labels["release"] = "ga"
+	revisions[3].Labels["release"] = "ga"
+	// the revision number decides the tiebreak rather than the name
+	revisions[3].Spec.Revision = 0
+	var listCount int
+	cli := newPagedRevisionClient(revisions, &listCount)
+	ctx := context.Background()
+
+	revision, err := util.GetDefinitionRevisionByLabel(ctx, cli, "worker", common.ComponentType, "release", "beta")
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-beta-2", revision.Name)
+
+	revision, err = util.GetDefinitionRevisionByLabel(ctx, cli, "worker", common.ComponentType, "release", "ga")
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-ga-3", revision.Name)
+
+	_, err = util.GetDefinitionRevisionByLabel(ctx, cli, "worker", common.ComponentType, "release", "alpha")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestDefinitionRevisionSelector(t *testing.T) {
+	testcases := map[common.DefinitionType]string{
+		common.ComponentType:    oam.LabelComponentDefinitionName,
+		common.TraitType:        oam.LabelTraitDefinitionName,
+		common.PolicyType:       oam.LabelPolicyDefinitionName,
+		common.WorkflowStepType: oam.LabelWorkflowStepDefinitionName,
+	}
+	for definitionType, labelKey := range testcases {
+		t.Run(string(definitionType), func(t *testing.T) {
+			selector, err := util.DefinitionRevisionSelector("worker", definitionType)
+			assert.NoError(t, err)
+			assert.Equal(t, labelKey+"=worker", selector.String())
+			assert.True(t, selector.Matches(labels.Set{labelKey: "worker"}))
+			assert.False(t, selector.Matches(labels.Set{labelKey: "webservice"}))
+		})
+	}
+
+	_, err := util.DefinitionRevisionSelector("worker", common.DefinitionType("Workload"))
+	assert.ErrorContains(t, err, `unsupported definition type "Workload"`)
+	_, err = util.DefinitionRevisionSelector("worker", "")
+	assert.Error(t, err)
+	_, err = util.DefinitionRevisionSelector("bad name", common.ComponentType)
+	assert.ErrorContains(t, err, "invalid definition name")
+}
+
+func TestAssertDefinitionLabelMapComplete(t *testing.T) {
+	assert.NoError(t, util.AssertDefinitionLabelMapComplete(util.DefinitionKindToNameLabel))
+
+	labels := make(map[common.DefinitionType]string, len(util.DefinitionKindToNameLabel))
+	for definitionType, label := range util.DefinitionKindToNameLabel {
+		labels[definitionType] = label
+	}
+	delete(labels, common.WorkflowStepType)
+	labels[common.PolicyType] = ""
+	assert.ErrorContains(t, util.AssertDefinitionLabelMapComplete(labels), "definition types [Policy,WorkflowStep] are missing")
+	assert.Equal(t, oam.LabelPolicyDefinitionName, util.DefinitionKindToNameLabel[common.PolicyType])
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// DefinitionRequest is a request to get definition by name
+type DefinitionRequest struct {
+	// Definition is the object to receive the resolved definition, e.g. new(v1beta1.ComponentDefinition)
+	Definition client.Object
+	// Name is the name of the definition
+	Name string
+}
+
+// Key returns the key of the request in the result of GetDefinitions, the definitions of different types may
+// share the same name, e.g. the ComponentDefinition and the TraitDefinition both named storage, so the key is
+// composed of the type and the name like `*v1beta1.TraitDefinition/storage`.
+func (r DefinitionRequest) Key() string {
+	return fmt.Sprintf("%T/%s", r.Definition, r.Name)
+}
+
+// newDefinitionList returns the list object for the given definition, it returns nil if the definition is not supported
+func newDefinitionList(definition client.Object) client.ObjectList {
+	switch definition.(type) {
+	case *v1beta1.ComponentDefinition:
+		return new(v1beta1.ComponentDefinitionList)
+	case *v1beta1.TraitDefinition:
+		return new(v1beta1.TraitDefinitionList)
+	case *v1beta1.PolicyDefinition:
+		return new(v1beta1.PolicyDefinitionList)
+	case *v1beta1.WorkflowStepDefinition:
+		return new(v1beta1.WorkflowStepDefinitionList)
+	case *v1beta1.WorkloadDefinition:
+		return new(v1beta1.WorkloadDefinitionList)
+	case *v1beta1.DefinitionRevision:
+		return new(v1beta1.DefinitionRevisionList)
+	default:
+		return nil
+	}
+}
+
+// GetDefinitions get definitions from two level namespace in one pass. The lookups are grouped by namespace and type,
+// and each group is resolved by one List call, the fallback order is the same as GetDefinition.
+// The definitions not found by List, including the ones in old clusters whose definition crd is cluster scope,
+// will be resolved by GetDefinition one by one. It returns the resolved definitions indexed by DefinitionRequest.Key and
+// the aggregated errors of the requests which cannot be resolved.
+func GetDefinitions(ctx context.Context, cli client.Reader, requests []DefinitionRequest) (map[string]client.Object, error) {
+	resolved := make(map[string]client.Object, len(requests))
+	pending := make([]DefinitionRequest, 0, len(requests))
+	var direct []DefinitionRequest
+	for _, req := range requests {
+		if newDefinitionList(req.Definition) == nil {
+			direct = append(direct, req)
+			continue
+		}
+		pending = append(pending, req)
+	}
+
+	var errs []error
+	for _, ns := range definitionNamespacesWithCtx(ctx) {
+		if len(pending) == 0 {
+			break
+		}
+		// index the listed definitions by type and name
+		listed := map[string]map[string]client.Object{}
+		listErrs := map[string]error{}
+		var unresolved []DefinitionRequest
+		for _, req := range pending {
+			kind := fmt.Sprintf("%T", req.Definition)
+			items, ok := listed[kind]
+			if !ok {
+				items, listErrs[kind] = listDefinitions(ctx, cli, req.Definition, ns)
+				listed[kind] = items
+			}
+			if err := listErrs[kind]; err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if item, found := items[req.Name]; found {
+				reflect.ValueOf(req.Definition).Elem().Set(reflect.ValueOf(item.DeepCopyObject()).Elem())
+				resolved[req.Key()] = req.Definition
+				continue
+			}
+			unresolved = append(unresolved, req)
+		}
+		pending = unresolved
+	}
+
+	for _, req := range append(pending, direct...) {
+		if err := GetDefinition(ctx, cli, req.Definition, req.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resolved[req.Key()] = req.Definition
+	}
+	return resolved, utilerrors.Reduce(utilerrors.NewAggregate(errs))
+}
+
+// ListVisibleDefinitions lists all the definitions of the given type which are visible to the applications in the
+// app namespace, which is the union of the definitions in the app namespace and the system namespace. The app level
+// definitions shadow the system level ones with the same name. The returned definitions are sorted by name.
+func ListVisibleDefinitions(ctx context.Context, cli client.Reader, definitionType common.DefinitionType, appNs string) ([]client.Object, error) {
+	definition, err := NewDefinitionObject(definitionType)
+	if err != nil {
+		return nil, err
+	}
+	visible := map[string]client.Object{}
+	// list the system namespace first, so the app level definitions override the system level ones
+	for _, ns := range []string{oam.SystemDefinitionNamespace, appNs} {
+		if ns == "" {
+			continue
+		}
+		items, err := listDefinitions(ctx, cli, definition, ns)
+		if err != nil {
+			return nil, err
+		}
+		for name, item := range items {
+			visible[name] = item
+		}
+	}
+	names := make([]string, 0, len(visible))
+	for name := range visible {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	definitions := make([]client.Object, 0, len(names))
+	for _, name := range names {
+		definitions = append(definitions, visible[name])
+	}
+	return definitions, nil
+}
+
+// ValidateApplicationDefinitions checks if the component and trait types in the Application reference the installed
+// definitions, the pinned revisions like worker@v1.3.1 are resolved by GetCapabilityDefinition. The app namespace
+// in context is used to search the definitions, or the namespace of the Application if not set. It returns the
+// sorted definition names which cannot be resolved, and the aggregated errors other than not found.
+func ValidateApplicationDefinitions(ctx context.Context, cli client.Reader, app *v1beta1.Application) ([]string, error) {
+	if _, ok := HasAppNamespace(ctx); !ok {
+		ctx = SetNamespaceInCtx(ctx, app.Namespace)
+	}
+	var unresolved []string
+	var errs []error
+	checked := map[string]bool{}
+	check := func(definition client.Object, definitionName string) {
+		key := fmt.Sprintf("%T/%s", definition, definitionName)
+		if checked[key] {
+			return
+		}
+		checked[key] = true
+		if err := GetCapabilityDefinition(ctx, cli, definition, definitionName, app.GetAnnotations()); err != nil {
+			if IsDefinitionNotFound(err) {
+				unresolved = append(unresolved, definitionName)
+				return
+			}
+			errs = append(errs, err)
+		}
+	}
+	for _, comp := range app.Spec.Components {
+		check(new(v1beta1.ComponentDefinition), comp.Type)
+		for _, trait := range comp.Traits {
+			if IsDummyTraitType(trait.Type) {
+				continue
+			}
+			check(new(v1beta1.TraitDefinition), trait.Type)
+		}
+	}
+	sort.Strings(unresolved)
+	return unresolved, utilerrors.Reduce(utilerrors.NewAggregate(errs))
+}
+
+// DefinitionAliasOption is the option for GetDefinitionByAlias
+type DefinitionAliasOption func(*definitionAliasConfig)
+
+type definitionAliasConfig struct {
+	label string
+}
+
+// WithDefinitionAliasLabel sets the label which records the alias of the definitions, oam.LabelDefinitionAlias is
+// used by default
+func WithDefinitionAliasLabel(label string) DefinitionAliasOption {
+	return func(cfg *definitionAliasConfig) {
+		cfg.label = label
+	}
+}
+
+// GetDefinitionByAlias gets the definition whose alias label equals to the alias, e.g. the definition named
+// webservice-worker-v2 can be resolved by the alias worker. The namespaces are searched in the same fallback order
+// as GetDefinition, and the first namespace which has the alias wins. An error will be returned if multiple
+// definitions in the namespace have the same alias, and a NotFound error will be returned if nothing matches.
+func GetDefinitionByAlias(ctx context.Context, cli client.Reader, into client.Object, alias string, opts ...DefinitionAliasOption) error {
+	cfg := &definitionAliasConfig{label: oam.LabelDefinitionAlias}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	var gvk schema.GroupVersionKind
+	switch into.(type) {
+	case *v1beta1.ComponentDefinition:
+		gvk = v1beta1.ComponentDefinitionGroupVersionKind
+	case *v1beta1.TraitDefinition:
+		gvk = v1beta1.TraitDefinitionGroupVersionKind
+	case *v1beta1.PolicyDefinition:
+		gvk = v1beta1.PolicyDefinitionGroupVersionKind
+	case *v1beta1.WorkflowStepDefinition:
+		gvk = v1beta1.WorkflowStepDefinitionGroupVersionKind
+	case *v1beta1.WorkloadDefinition:
+		gvk = v1beta1.WorkloadDefinitionGroupVersionKind
+	default:
+		return fmt.Errorf("invalid definition type for alias %s", alias)
+	}
+	for _, ns := range definitionNamespacesWithCtx(ctx) {
+		list, err := GetObjectsGivenGVKAndLabels(ctx, cli, gvk, ns, map[string]string{cfg.label: alias})
+		if err != nil {
+			return err
+		}
+		switch len(list.Items) {
+		case 0:
+			continue
+		case 1:
+			return runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[0].Object, into)
+		default:
+			names := make([]string, 0, len(list.Items))
+			for _, item := range list.Items {
+				names = append(names, item.GetName())
+			}
+			sort.Strings(names)
+			return fmt.Errorf("alias %s is ambiguous in namespace %s, it matches %s %s", alias, ns, gvk.Kind, strings.Join(names, ", "))
+		}
+	}
+	return apierrors.NewNotFound(v1beta1.SchemeGroupVersion.WithResource(strings.ToLower(gvk.Kind)+"s").GroupResource(), alias)
+}
+
+func listDefinitions(ctx context.Context, cli client.Reader, definition client.Object, namespace string) (map[string]client.Object, error) {
+	list := newDefinitionList(definition)
+	if err := cli.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]client.Object, len(objs))
+	for _, obj := range objs {
+		if o, ok := obj.(client.Object); ok {
+			items[o.GetName()] = o
+		}
+	}
+	return items, nil
+}
+
+// ValidateDefinitionNameNotRevisionLike rejects the definition name ending with the revision number segment like
+// `foo-v2`, which is indistinguishable from the name of the second DefinitionRevision of definition `foo`. It should
+// be checked when the definition is created, the existing definitions are not affected.
+func ValidateDefinitionNameNotRevisionLike(name string) error {
+	idx := strings.LastIndex(name, "-")
+	if idx <= 0 || !revisionNumRegexp.MatchString(name[idx+1:]) {
+		return nil
+	}
+	return fmt.Errorf("definition name %q is ambiguous with the revision %s of definition %q, the suffix like `-v1` is reserved for definition revisions",
+		name, name[idx+1:], name[:idx])
+}
+
+// GetComponentDefinitionForApp resolves the ComponentDefinition of the named component in the Application, the
+// pinned revision is resolved like GetCapabilityDefinition with the annotations of the Application, so the
+// auto-update is honored. The app namespace in context is used to search the definitions, or the namespace of the
+// Application if not set. The returned revision name is the one returned by GetDefinitionAndRevision.
+func GetComponentDefinitionForApp(ctx context.Context, cli client.Reader, app *v1beta1.Application,
+	componentName string) (*v1beta1.ComponentDefinition, string, error) {
+	idx := slices.IndexFunc(app.Spec.Components, func(comp common.ApplicationComponent) bool {
+		return comp.Name == componentName
+	})
+	if idx < 0 {
+		return nil, "", fmt.Errorf("component %s is not found in application %s/%s", componentName, app.Namespace, app.Name)
+	}
+	if _, ok := HasAppNamespace(ctx); !ok {
+		ctx = SetNamespaceInCtx(ctx, app.Namespace)
+	}
+	def := new(v1beta1.ComponentDefinition)
+	revisionName, err := GetDefinitionAndRevision(ctx, cli, def, app.Spec.Components[idx].Type, app.GetAnnotations())
+	if err != nil {
+		return nil, "", err
+	}
+	return def, revisionName, nil
+}
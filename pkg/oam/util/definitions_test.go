@@ -0,0 +1,367 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+func TestGetDefinitions(t *testing.T) {
+	ctx := util.SetNamespaceInCtx(context.Background(), "vela-app")
+	compDefs := map[string]v1beta1.ComponentDefinition{}
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("comp-%d", i)
+		ns := "vela-system"
+		// the app level definition overrides the system level one
+		if i == 0 {
+			ns = "vela-app"
+		}
+		compDefs[ns+"/"+name] = v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}}
+		names = append(names, name)
+	}
+	compDefs["vela-system/comp-0"] = v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "comp-0", Namespace: "vela-system"}}
+	traitDefs := []v1beta1.TraitDefinition{
+		{ObjectMeta: metav1.ObjectMeta{Name: "scaler", Namespace: "vela-system"}},
+		// the trait shares the same name with a component
+		{ObjectMeta: metav1.ObjectMeta{Name: "comp-1", Namespace: "vela-system", Labels: map[string]string{"type": "trait"}}},
+	}
+
+	var getCount, listCount int
+	cli := test.MockClient{
+		MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+			getCount++
+			switch o := obj.(type) {
+			case *v1beta1.ComponentDefinition:
+				if def, ok := compDefs[key.Namespace+"/"+key.Name]; ok {
+					*o = def
+					return nil
+				}
+			case *v1beta1.TraitDefinition:
+				for _, traitDef := range traitDefs {
+					if key.Namespace == traitDef.Namespace && key.Name == traitDef.Name {
+						*o = traitDef
+						return nil
+					}
+				}
+			}
+			return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "definition"}, key.Name)
+		},
+		MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			listCount++
+			listOpts := &client.ListOptions{}
+			listOpts.ApplyOptions(opts)
+			switch l := list.(type) {
+			case *v1beta1.ComponentDefinitionList:
+				for _, def := range compDefs {
+					if def.Namespace == listOpts.Namespace {
+						l.Items = append(l.Items, def)
+					}
+				}
+			case *v1beta1.TraitDefinitionList:
+				for _, traitDef := range traitDefs {
+					if traitDef.Namespace == listOpts.Namespace {
+						l.Items = append(l.Items, traitDef)
+					}
+				}
+			}
+			return nil
+		},
+	}
+
+	// the naive loop
+	for _, name := range names {
+		assert.NoError(t, util.GetDefinition(ctx, &cli, new(v1beta1.ComponentDefinition), name))
+	}
+	naiveCount := getCount + listCount
+
+	getCount, listCount = 0, 0
+	var requests []util.DefinitionRequest
+	for _, name := range names {
+		requests = append(requests, util.DefinitionRequest{Definition: new(v1beta1.ComponentDefinition), Name: name})
+	}
+	requests = append(requests,
+		util.DefinitionRequest{Definition: new(v1beta1.TraitDefinition), Name: "scaler"},
+		util.DefinitionRequest{Definition: new(v1beta1.TraitDefinition), Name: "comp-1"})
+	resolved, err := util.GetDefinitions(ctx, &cli, requests)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(resolved))
+	assert.Equal(t, "vela-app", resolved[requests[0].Key()].GetNamespace())
+	assert.Equal(t, "vela-system", resolved["*v1beta1.ComponentDefinition/comp-1"].GetNamespace())
+	assert.Equal(t, "vela-system", resolved["*v1beta1.TraitDefinition/scaler"].GetNamespace())
+	// the component and the trait with the same name are both resolved
+	assert.IsType(t, &v1beta1.ComponentDefinition{}, resolved[requests[1].Key()])
+	assert.IsType(t, &v1beta1.TraitDefinition{}, resolved[requests[6].Key()])
+	assert.Equal(t, "trait", resolved[requests[6].Key()].GetLabels()["type"])
+	assert.Empty(t, resolved[requests[1].Key()].GetLabels())
+	assert.Equal(t, "comp-2", requests[2].Definition.GetName())
+	assert.Equal(t, 0, getCount)
+	assert.Less(t, getCount+listCount, naiveCount)
+
+	// the error of definitions not found is the same as GetDefinition
+	getCount, listCount = 0, 0
+	resolved, err = util.GetDefinitions(ctx, &cli, []util.DefinitionRequest{
+		{Definition: new(v1beta1.ComponentDefinition), Name: "comp-1"},
+		{Definition: new(v1beta1.ComponentDefinition), Name: "not-exist"},
+	})
+	assert.Equal(t, 1, len(resolved))
+	assert.Error(t, err)
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, util.GetDefinition(ctx, &cli, new(v1beta1.ComponentDefinition), "not-exist").Error(), err.Error())
+}
+
+func TestListVisibleDefinitions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1beta1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "vela-system", Labels: map[string]string{"level": "system"}}},
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "webservice", Namespace: "vela-system", Labels: map[string]string{"level": "system"}}},
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "vela-app", Labels: map[string]string{"level": "app"}}},
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "task", Namespace: "vela-app", Labels: map[string]string{"level": "app"}}},
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "cron", Namespace: "other", Labels: map[string]string{"level": "other"}}},
+		&v1beta1.TraitDefinition{ObjectMeta: metav1.ObjectMeta{Name: "scaler", Namespace: "vela-system"}},
+	).Build()
+	ctx := context.Background()
+
+	defs, err := util.ListVisibleDefinitions(ctx, cli, common.ComponentType, "vela-app")
+	assert.NoError(t, err)
+	levels := map[string]string{}
+	var names []string
+	for _, def := range defs {
+		assert.IsType(t, &v1beta1.ComponentDefinition{}, def)
+		names = append(names, def.GetName())
+		levels[def.GetName()] = def.GetLabels()["level"]
+	}
+	assert.Equal(t, []string{"task", "webservice", "worker"}, names)
+	assert.Equal(t, map[string]string{"task": "app", "webservice": "system", "worker": "app"}, levels)
+
+	defs, err = util.ListVisibleDefinitions(ctx, cli, common.ComponentType, "vela-system")
+	assert.NoError(t, err)
+	assert.Len(t, defs, 2)
+
+	defs, err = util.ListVisibleDefinitions(ctx, cli, common.TraitType, "vela-app")
+	assert.NoError(t, err)
+	assert.Len(t, defs, 1)
+	assert.Equal(t, "scaler", defs[0].GetName())
+
+	_, err = util.ListVisibleDefinitions(ctx, cli, "Unknown", "vela-app")
+	assert.Error(t, err)
+}
+
+func TestGetDefinitionByAlias(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1beta1.AddToScheme(scheme))
+	newDef := func(name, ns, alias string) *v1beta1.ComponentDefinition {
+		return &v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: ns, Labels: map[string]string{oam.LabelDefinitionAlias: alias},
+		}}
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newDef("webservice-worker-v2", "vela-system", "worker"),
+		newDef("app-worker", "vela-app", "worker"),
+		newDef("cron-task-v1", "vela-system", "cron"),
+		newDef("web-a", "vela-system", "web"),
+		newDef("web-b", "vela-system", "web"),
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{
+			Name: "custom-worker", Namespace: "vela-system", Labels: map[string]string{"custom.io/alias": "worker"},
+		}},
+	).Build()
+
+	def := &v1beta1.ComponentDefinition{}
+	assert.NoError(t, util.GetDefinitionByAlias(context.Background(), cli, def, "cron"))
+	assert.Equal(t, "cron-task-v1", def.Name)
+	assert.Equal(t, "vela-system", def.Namespace)
+
+	// the app namespace is searched first
+	def = &v1beta1.ComponentDefinition{}
+	assert.NoError(t, util.GetDefinitionByAlias(util.SetNamespaceInCtx(context.Background(), "vela-app"), cli, def, "worker"))
+	assert.Equal(t, "app-worker", def.Name)
+
+	err := util.GetDefinitionByAlias(context.Background(), cli, &v1beta1.ComponentDefinition{}, "missing")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	err = util.GetDefinitionByAlias(context.Background(), cli, &v1beta1.ComponentDefinition{}, "web")
+	assert.ErrorContains(t, err, "alias web is ambiguous in namespace vela-system, it matches ComponentDefinition web-a, web-b")
+
+	assert.Error(t, util.GetDefinitionByAlias(context.Background(), cli, &v1beta1.Application{}, "web"))
+
+	// the alias can be recorded by a custom label
+	def = &v1beta1.ComponentDefinition{}
+	assert.NoError(t, util.GetDefinitionByAlias(context.Background(), cli, def, "worker", util.WithDefinitionAliasLabel("custom.io/alias")))
+	assert.Equal(t, "custom-worker", def.Name)
+}
+
+func TestValidateApplicationDefinitions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1beta1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "webservice", Namespace: "vela-system"}},
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "task", Namespace: "vela-app"}},
+		&v1beta1.TraitDefinition{ObjectMeta: metav1.ObjectMeta{Name: "scaler", Namespace: "vela-system"}},
+		&v1beta1.DefinitionRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: "webservice-v1.0.0", Namespace: "vela-system",
+				Labels: map[string]string{oam.LabelComponentDefinitionName: "webservice"}},
+			Spec: v1beta1.DefinitionRevisionSpec{
+				DefinitionType:      common.ComponentType,
+				ComponentDefinition: v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "webservice"}},
+			},
+		},
+	).Build()
+	newApp := func(comps ...common.ApplicationComponent) *v1beta1.Application {
+		return &v1beta1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "vela-app"},
+			Spec:       v1beta1.ApplicationSpec{Components: comps},
+		}
+	}
+
+	unresolved, err := util.ValidateApplicationDefinitions(context.Background(), cli, newApp(
+		common.ApplicationComponent{Name: "a", Type: "webservice", Traits: []common.ApplicationTrait{{Type: "scaler"}}},
+		common.ApplicationComponent{Name: "b", Type: "webservice@v1.0.0"},
+		common.ApplicationComponent{Name: "c", Type: "task"},
+	))
+	assert.NoError(t, err)
+	assert.Empty(t, unresolved)
+
+	unresolved, err = util.ValidateApplicationDefinitions(context.Background(), cli, newApp(
+		common.ApplicationComponent{Name: "a", Type: "webservice", Traits: []common.ApplicationTrait{{Type: "gateway"}, {Type: "scaler"}}},
+		common.ApplicationComponent{Name: "b", Type: "webservice@v2.0.0"},
+		common.ApplicationComponent{Name: "c", Type: "worker", Traits: []common.ApplicationTrait{{Type: "gateway"}}},
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gateway", "webservice@v2.0.0", "worker"}, unresolved)
+
+	// the revisions which cannot be resolved by constraint or auto-update are unresolved as well
+	app := newApp(
+		common.ApplicationComponent{Name: "a", Type: "webservice@^2.0"},
+		common.ApplicationComponent{Name: "b", Type: "webservice@v9"},
+		common.ApplicationComponent{Name: "c", Type: "webservice@v1"},
+	)
+	app.SetAnnotations(map[string]string{oam.AnnotationAutoUpdate: "true"})
+	unresolved, err = util.ValidateApplicationDefinitions(context.Background(), cli, app)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"webservice@^2.0", "webservice@v9"}, unresolved)
+
+	// the app namespace in context takes precedence over the namespace of the Application
+	unresolved, err = util.ValidateApplicationDefinitions(util.SetNamespaceInCtx(context.Background(), "other"), cli, newApp(
+		common.ApplicationComponent{Name: "c", Type: "task"},
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"task"}, unresolved)
+}
+
+func TestValidateDefinitionNameNotRevisionLike(t *testing.T) {
+	testcases := map[string]struct {
+		name    string
+		wantErr bool
+	}{
+		"revision like name":          {name: "foo-v2", wantErr: true},
+		"multi-digit revision suffix": {name: "my-trait-v10", wantErr: true},
+		"version word":                {name: "foo-version2"},
+		"no dash":                     {name: "foov2"},
+		"version in the middle":       {name: "foo-v2-bar"},
+		"suffix without number":       {name: "foo-v"},
+		"plain name":                  {name: "webservice"},
+		"only revision segment":       {name: "v2"},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := util.ValidateDefinitionNameNotRevisionLike(tt.name)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "ambiguous with the revision")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetComponentDefinitionForApp(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1beta1.AddToScheme(scheme))
+	newRevision := func(version string, revision int64) *v1beta1.DefinitionRevision {
+		return &v1beta1.DefinitionRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "webservice-" + version,
+				Namespace: "vela-system",
+				Labels:    map[string]string{oam.LabelComponentDefinitionName: "webservice"},
+			},
+			Spec: v1beta1.DefinitionRevisionSpec{
+				Revision:       revision,
+				DefinitionType: common.ComponentType,
+				ComponentDefinition: v1beta1.ComponentDefinition{
+					ObjectMeta: metav1.ObjectMeta{Name: "webservice"},
+					Spec:       v1beta1.ComponentDefinitionSpec{Version: strings.TrimPrefix(version, "v")},
+				},
+			},
+		}
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1beta1.ComponentDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "webservice", Namespace: "vela-system"},
+			Spec:       v1beta1.ComponentDefinitionSpec{Version: "1.2.0"},
+		},
+		&v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "task", Namespace: "vela-app"}},
+		newRevision("v1.0.0", 1),
+		newRevision("v1.2.0", 2),
+	).Build()
+	app := &v1beta1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "vela-app"},
+		Spec: v1beta1.ApplicationSpec{Components: []common.ApplicationComponent{
+			{Name: "pinned", Type: "webservice@v1.0.0"},
+			{Name: "floating", Type: "webservice@v1"},
+			{Name: "local", Type: "task"},
+		}},
+	}
+	ctx := context.Background()
+
+	def, revisionName, err := util.GetComponentDefinitionForApp(ctx, cli, app, "pinned")
+	assert.NoError(t, err)
+	assert.Equal(t, "webservice-v1.0.0", revisionName)
+	assert.Equal(t, "1.0.0", def.Spec.Version)
+
+	// the definition in the app namespace is resolved
+	def, _, err = util.GetComponentDefinitionForApp(ctx, cli, app, "local")
+	assert.NoError(t, err)
+	assert.Equal(t, "vela-app", def.Namespace)
+
+	// the auto-update annotation of the Application is honored
+	app.SetAnnotations(map[string]string{oam.AnnotationAutoUpdate: "true"})
+	def, revisionName, err = util.GetComponentDefinitionForApp(ctx, cli, app, "floating")
+	assert.NoError(t, err)
+	assert.Equal(t, "webservice-v1.2.0", revisionName)
+	assert.Equal(t, "1.2.0", def.Spec.Version)
+
+	_, _, err = util.GetComponentDefinitionForApp(ctx, cli, app, "missing")
+	assert.ErrorContains(t, err, "component missing is not found in application vela-app/app")
+}
@@ -17,30 +17,50 @@ limitations under the License.
 package util
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"hash"
 	"hash/fnv"
+	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	velaslices "github.com/kubevela/pkg/util/slices"
+	"github.com/mitchellh/hashstructure/v2"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/strings/slices"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 
-	"github.com/Masterminds/semver"
+	"github.com/Masterminds/semver/v3"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/condition"
@@ -116,6 +136,38 @@ const (
 	ReferWorkload WorkloadType = "ReferWorkload"
 )
 
+// ValidWorkloadTypes returns all the valid workload types
+func ValidWorkloadTypes() []WorkloadType {
+	return []WorkloadType{ComponentDef, KubeDef, HELMDef, TerraformDef, ReferWorkload}
+}
+
+// String returns the string of the workload type
+func (t WorkloadType) String() string {
+	return string(t)
+}
+
+// IsValidWorkloadType checks whether the workload type is valid
+func IsValidWorkloadType(t WorkloadType) bool {
+	for _, valid := range ValidWorkloadTypes() {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseWorkloadType parses the string to a valid workload type
+func ParseWorkloadType(s string) (WorkloadType, error) {
+	if t := WorkloadType(s); IsValidWorkloadType(t) {
+		return t, nil
+	}
+	var valid []string
+	for _, t := range ValidWorkloadTypes() {
+		valid = append(valid, t.String())
+	}
+	return "", errors.Errorf("unknown workload type %q, valid values are: %s", s, strings.Join(valid, ", "))
+}
+
 type namespaceContextKey int
 
 const (
@@ -123,6 +175,8 @@ const (
 	AppDefinitionNamespace namespaceContextKey = iota
 	// XDefinitionNamespace is context key to define the namespace, which x-definition(Component/Trait) is installed to
 	XDefinitionNamespace
+	// OverrideNamespace is context key to define the namespace which overrides the namespace of application resources
+	OverrideNamespace
 )
 
 // DefinitionKindToNameLabel records DefinitionRevision types and labels to search its name
@@ -133,6 +187,22 @@ var DefinitionKindToNameLabel = map[common.DefinitionType]string{
 	common.WorkflowStepType: oam.LabelWorkflowStepDefinitionName,
 }
 
+// AssertDefinitionLabelMapComplete checks every definition type in common.AllDefinitionTypes has the name label in
+// the given labels map, otherwise the DefinitionRevisions of the missing type cannot be looked up.
+// Pass DefinitionKindToNameLabel to check the map used by the lookups.
+func AssertDefinitionLabelMapComplete(labels map[common.DefinitionType]string) error {
+	var missing []string
+	for _, definitionType := range common.AllDefinitionTypes {
+		if labels[definitionType] == "" {
+			missing = append(missing, string(definitionType))
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("definition types [%s] are missing in DefinitionKindToNameLabel", strings.Join(missing, ","))
+	}
+	return nil
+}
+
 // A ConditionedObject is an Object type with condition field
 type ConditionedObject interface {
 	client.Object
@@ -164,6 +234,47 @@ func GetXDefinitionNamespaceWithCtx(ctx context.Context) string {
 	return oam.SystemDefinitionNamespace
 }
 
+// ResolveXDefinitionNamespace resolves the effective x-definition namespace of the object, the namespace set in
+// context by SetXDefinitionNamespaceInCtx takes precedence over the one in the annotation
+// `app.oam.dev/xdefinition-namespace` of the object, and the system namespace is used if neither is set.
+func ResolveXDefinitionNamespace(ctx context.Context, obj labelAnnotationObject) string {
+	if xNs, _ := ctx.Value(XDefinitionNamespace).(string); len(xNs) > 0 {
+		return xNs
+	}
+	if obj != nil {
+		if xNs := obj.GetAnnotations()[oam.AnnotationXDefinitionNamespace]; len(xNs) > 0 {
+			return xNs
+		}
+	}
+	return oam.SystemDefinitionNamespace
+}
+
+// definitionNamespacesWithCtx returns the namespaces to search definitions in order, i.e. the app namespace,
+// the x-definition namespace and the system namespace, the duplicated ones are removed
+func definitionNamespacesWithCtx(ctx context.Context) []string {
+	var namespaces []string
+	for _, ns := range []string{GetDefinitionNamespaceWithCtx(ctx), GetXDefinitionNamespaceWithCtx(ctx), oam.SystemDefinitionNamespace} {
+		if !slices.Contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// HasAppNamespace returns the app namespace in context and whether it is set, unlike GetDefinitionNamespaceWithCtx,
+// it does not fall back to the system namespace
+func HasAppNamespace(ctx context.Context) (string, bool) {
+	ns, ok := ctx.Value(AppDefinitionNamespace).(string)
+	return ns, ok
+}
+
+// HasXDefinitionNamespace returns the x-definition namespace in context and whether it is set, unlike
+// GetXDefinitionNamespaceWithCtx, it does not fall back to the system namespace
+func HasXDefinitionNamespace(ctx context.Context) (string, bool) {
+	ns, ok := ctx.Value(XDefinitionNamespace).(string)
+	return ns, ok && len(ns) > 0
+}
+
 // SetNamespaceInCtx set app namespace in context,
 // Sometimes webhook handler may receive a request that appNs is empty string, and will cause error when search definition
 // So if namespace is empty, it will use `default` namespace by default.
@@ -176,6 +287,17 @@ func SetNamespaceInCtx(ctx context.Context, namespace string) context.Context {
 	return ctx
 }
 
+// SetNamespaceInCtxValidated set app namespace in context like SetNamespaceInCtx, but returns an error if the
+// namespace is not a valid DNS label. The empty namespace is still regarded as the `default` namespace.
+func SetNamespaceInCtxValidated(ctx context.Context, namespace string) (context.Context, error) {
+	if namespace != "" {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) != 0 {
+			return ctx, errors.Errorf("invalid namespace %q:%s", namespace, strings.Join(errs, ","))
+		}
+	}
+	return SetNamespaceInCtx(ctx, namespace), nil
+}
+
 // SetXDefinitionNamespaceInCtx set x-definition namespace in context,
 // Sometimes x-definition is installed to customized namespace
 // So it is empty, it will use `vela-system` namespace by default.
@@ -187,38 +309,173 @@ func SetXDefinitionNamespaceInCtx(ctx context.Context, namespace string) context
 	return ctx
 }
 
-// GetDefinition get definition from two level namespace
+// SetOverrideNamespaceInCtx set the namespace which overrides the namespace of application resources in context,
+// it will be read by the NamespaceAccessor created by NewContextAwareNamespaceAccessor
+func SetOverrideNamespaceInCtx(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, OverrideNamespace, namespace)
+}
+
+// GetOverrideNamespaceWithCtx will get the override namespace from context, it returns empty if not set
+func GetOverrideNamespaceWithCtx(ctx context.Context) string {
+	ns, _ := ctx.Value(OverrideNamespace).(string)
+	return ns
+}
+
+// GetDefinitionOption is the option for getting definition
+type GetDefinitionOption func(*getDefinitionConfig)
+
+type getDefinitionConfig struct {
+	disableClusterScopeFallback bool
+}
+
+// WithoutClusterScopeFallback disables the compatibility path which gets the definition in cluster scope when it is
+// not found in the namespace, so that only one namespaced Get is issued for each namespace. The compatibility path is
+// only needed by old clusters whose definition crd is cluster scope.
+func WithoutClusterScopeFallback() GetDefinitionOption {
+	return func(cfg *getDefinitionConfig) {
+		cfg.disableClusterScopeFallback = true
+	}
+}
+
+func newGetDefinitionConfig(opts ...GetDefinitionOption) *getDefinitionConfig {
+	cfg := &getDefinitionConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// GetDefinition get definition from two level namespace. The cli is usually the cached client of the manager,
+// which is preferred on the hot paths like rendering applications, but the result may be stale for a while after
+// the definition is created or updated. Use GetDefinitionLive if the latest definition is required.
 func GetDefinition(ctx context.Context, cli client.Reader, definition client.Object, definitionName string) error {
-	appNs := GetDefinitionNamespaceWithCtx(ctx)
-	if err := cli.Get(ctx, types.NamespacedName{Name: definitionName, Namespace: appNs}, definition); err != nil {
-		if !apierrors.IsNotFound(err) {
-			return err
-		}
+	_, err := GetDefinitionResolved(ctx, cli, definition, definitionName)
+	return err
+}
+
+// GetDefinitionLive get definition from two level namespace like GetDefinition, but reads it through the given
+// non-caching reader, e.g. the APIReader of the manager, so that the definition just created or updated is visible.
+// It issues requests to the apiserver directly, so it should only be used when the stale read is unacceptable.
+func GetDefinitionLive(ctx context.Context, apiReader client.Reader, definition client.Object, definitionName string) error {
+	return GetDefinition(ctx, apiReader, definition, definitionName)
+}
+
+// GetDefinitionWithOptions get definition from two level namespace like GetDefinition, the lookup can be tuned by
+// options like WithoutClusterScopeFallback.
+func GetDefinitionWithOptions(ctx context.Context, cli client.Reader, definition client.Object, definitionName string, opts ...GetDefinitionOption) error {
+	_, err := getDefinitionWithNamespaces(ctx, cli, definition, definitionName, newGetDefinitionConfig(opts...), definitionNamespacesWithCtx(ctx)...)
+	return err
+}
+
+// GetDefinitionResolved get definition from two level namespace like GetDefinition, and returns the namespace where
+// the definition is resolved from. The namespace is empty if the definition is resolved in cluster scope for compatibility.
+func GetDefinitionResolved(ctx context.Context, cli client.Reader, definition client.Object, definitionName string) (string, error) {
+	return getDefinitionWithNamespaces(ctx, cli, definition, definitionName, newGetDefinitionConfig(), definitionNamespacesWithCtx(ctx)...)
+}
+
+// GetDefinitionWithNamespaces get definition from the given namespaces in order, the first one found will be returned.
+// The first namespace is taken as the app namespace. For each namespace other than the app namespace, as well as the
+// app namespace which is also the x-definition namespace or the system namespace, it will also try to get the
+// definition in cluster scope for compatibility.
+func GetDefinitionWithNamespaces(ctx context.Context, cli client.Reader, definition client.Object, definitionName string, namespaces ...string) error {
+	_, err := getDefinitionWithNamespaces(ctx, cli, definition, definitionName, newGetDefinitionConfig(), namespaces...)
+	return err
+}
 
-		for _, ns := range []string{GetXDefinitionNamespaceWithCtx(ctx), oam.SystemDefinitionNamespace} {
-			err = GetDefinitionFromNamespace(ctx, cli, definition, definitionName, ns)
+func getDefinitionWithNamespaces(ctx context.Context, cli client.Reader, definition client.Object, definitionName string, cfg *getDefinitionConfig, namespaces ...string) (string, error) {
+	if len(namespaces) == 0 {
+		return "", errors.Errorf("no namespace is specified to get definition %s", definitionName)
+	}
+	DefinitionLookupCounter.Inc()
+	defer func(begin time.Time) {
+		DefinitionLookupDurationHistogram.Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	appNamespace := namespaces[0]
+	var err error
+	for i, ns := range namespaces {
+		// skip the namespaces already searched
+		if i > 0 && slices.Contains(namespaces[:i], ns) {
+			continue
+		}
+		if i > 0 && ns == oam.SystemDefinitionNamespace {
+			DefinitionSystemNamespaceFallbackCounter.Inc()
+		}
+		if ns == appNamespace && ns != oam.SystemDefinitionNamespace && ns != GetXDefinitionNamespaceWithCtx(ctx) {
+			// the definition in the app namespace is always namespaced, no need to fall back to cluster scope
+			if err = cli.Get(ctx, types.NamespacedName{Name: definitionName, Namespace: ns}, definition); err == nil {
+				return ns, nil
+			}
 			if !apierrors.IsNotFound(err) {
-				return err
+				return "", err
 			}
+			continue
+		}
+		var resolvedNamespace string
+		resolvedNamespace, err = getDefinitionFromNamespace(ctx, cli, definition, definitionName, ns, cfg)
+		if !apierrors.IsNotFound(err) {
+			return resolvedNamespace, err
 		}
-		return err
 	}
-
-	return nil
+	return "", err
 }
 
 // GetDefinitionFromNamespace get definition from namespace.
 func GetDefinitionFromNamespace(ctx context.Context, cli client.Reader, definition client.Object, definitionName, namespace string) error {
+	_, err := getDefinitionFromNamespace(ctx, cli, definition, definitionName, namespace, newGetDefinitionConfig())
+	return err
+}
+
+func getDefinitionFromNamespace(ctx context.Context, cli client.Reader, definition client.Object, definitionName, namespace string, cfg *getDefinitionConfig) (string, error) {
 	if err := cli.Get(ctx, types.NamespacedName{Name: definitionName, Namespace: namespace}, definition); err != nil {
-		if apierrors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) && !cfg.disableClusterScopeFallback {
 			// compatibility code for old clusters those definition crd is cluster scope
+			DefinitionClusterScopeFallbackCounter.Inc()
 			var newErr error
-			if newErr = cli.Get(ctx, types.NamespacedName{Name: definitionName}, definition); checkRequestNamespaceError(newErr) {
-				return err
+			if newErr = cli.Get(ctx, types.NamespacedName{Name: definitionName}, definition); IsEmptyNamespaceError(newErr) {
+				return "", err
 			}
-			return newErr
+			return "", newErr
 		}
-		return err
+		return "", err
+	}
+	return namespace, nil
+}
+
+// IsDefinitionNotFound checks if the error returned by the definition lookups like GetDefinition and
+// GetDefinitionFromNamespace means the definition is not found. Besides the NotFound error, the error of the
+// cluster-scope compatibility path which requires a namespace is also regarded as not found, and the wrapped
+// errors are unwrapped.
+func IsDefinitionNotFound(err error) bool {
+	return apierrors.IsNotFound(err) || IsEmptyNamespaceError(err)
+}
+
+// UpdateDefinitionWithRetry gets the latest definition, applies the mutate func on it and updates it, the whole
+// get-modify-update flow will be retried on conflict. The failure is reported with the update error of the
+// definition type, e.g. ErrUpdateComponentDefinition. The mutate func should modify the given definition only.
+func UpdateDefinitionWithRetry(ctx context.Context, cli client.Client, def client.Object, mutate func()) error {
+	var errFormat string
+	switch def.(type) {
+	case *v1beta1.ComponentDefinition:
+		errFormat = ErrUpdateComponentDefinition
+	case *v1beta1.TraitDefinition:
+		errFormat = ErrUpdateTraitDefinition
+	case *v1beta1.PolicyDefinition:
+		errFormat = ErrUpdatePolicyDefinition
+	case *v1beta1.WorkflowStepDefinition:
+		errFormat = ErrUpdateWorkflowStepDefinition
+	default:
+		return fmt.Errorf("invalid definition type for %v", def.GetName())
+	}
+	key := client.ObjectKeyFromObject(def)
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if err := cli.Get(ctx, key, def); err != nil {
+			return err
+		}
+		mutate()
+		return cli.Update(ctx, def)
+	}); err != nil {
+		return fmt.Errorf(errFormat, key.Name, err)
 	}
 	return nil
 }
@@ -226,28 +483,91 @@ func GetDefinitionFromNamespace(ctx context.Context, cli client.Reader, definiti
 // GetCapabilityDefinition can get different versions of ComponentDefinition/TraitDefinition
 func GetCapabilityDefinition(ctx context.Context, cli client.Reader, definition client.Object,
 	definitionName string, annotations map[string]string) error {
+	_, err := GetCapabilityDefinitionWithRevision(ctx, cli, definition, definitionName, annotations)
+	return err
+}
+
+// GetCapabilityDefinitionWithRevision gets the definition like GetCapabilityDefinition, and returns the name of
+// the DefinitionRevision which is resolved. The revision name is empty if the latest live definition is used.
+func GetCapabilityDefinitionWithRevision(ctx context.Context, cli client.Reader, definition client.Object,
+	definitionName string, annotations map[string]string) (revisionName string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+	ctx, span := startSpan(ctx, "GetCapabilityDefinition", AttributeDefinitionName.String(definitionName))
+	defer func() { endSpan(span, err) }()
+
 	definitionType, err := getDefinitionType(definition)
 	if err != nil {
-		return err
+		return "", err
 	}
+	span.SetAttributes(AttributeDefinitionType.String(string(definitionType)))
 	isLatestRevision, defRev, err := fetchDefinitionRevision(ctx, cli, definitionName, definitionType, annotations)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if isLatestRevision {
+		return "", traceClientCall(ctx, "GetDefinition", func(ctx context.Context) error {
+			return GetDefinition(ctx, cli, definition, definitionName)
+		}, AttributeDefinitionName.String(definitionName))
+	}
+	if err = ExtractDefinitionFromRevision(defRev, definition); err != nil {
+		return "", errors.Wrapf(err, "failed to get definition %s", definitionName)
+	}
+	return defRev.Name, nil
+}
 
-		return GetDefinition(ctx, cli, definition, definitionName)
+// GetDefinitionAndRevision gets the definition like GetCapabilityDefinition, and returns the name of the revision
+// which is actually used. For the pinned or auto-updated references, it's the name of the resolved DefinitionRevision.
+// For the references to the latest definition, it's the latest revision recorded in the status of the live
+// definition, which is empty if the definition has not been revisioned yet.
+func GetDefinitionAndRevision(ctx context.Context, cli client.Reader, into client.Object, name string,
+	annotations map[string]string) (revisionName string, err error) {
+	if revisionName, err = GetCapabilityDefinitionWithRevision(ctx, cli, into, name, annotations); err != nil || revisionName != "" {
+		return revisionName, err
 	}
-	switch def := definition.(type) {
+	return latestRevisionNameOfDefinition(into), nil
+}
+
+// latestRevisionNameOfDefinition returns the name of the latest revision recorded in the status of the definition
+func latestRevisionNameOfDefinition(def client.Object) string {
+	var latest *common.Revision
+	switch d := def.(type) {
 	case *v1beta1.ComponentDefinition:
-		*def = defRev.Spec.ComponentDefinition
+		latest = d.Status.LatestRevision
 	case *v1beta1.TraitDefinition:
-		*def = defRev.Spec.TraitDefinition
+		latest = d.Status.LatestRevision
 	case *v1beta1.PolicyDefinition:
-		*def = defRev.Spec.PolicyDefinition
+		latest = d.Status.LatestRevision
 	case *v1beta1.WorkflowStepDefinition:
-		*def = defRev.Spec.WorkflowStepDefinition
-	default:
+		latest = d.Status.LatestRevision
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.Name
+}
+
+// ExtractDefinitionFromRevision copies the definition embedded in the DefinitionRevision into the given object
+// according to its type, an error will be returned if the DefinitionType of the revision doesn't match it.
+func ExtractDefinitionFromRevision(defRev *v1beta1.DefinitionRevision, into client.Object) error {
+	definitionType, err := getDefinitionType(into)
+	if err != nil {
+		return err
+	}
+	if defRev.Spec.DefinitionType != definitionType {
+		return fmt.Errorf("definition revision %s has type %q, but %q is requested",
+			defRev.Name, defRev.Spec.DefinitionType, definitionType)
+	}
+	switch def := into.(type) {
+	case *v1beta1.ComponentDefinition:
+		*def = *defRev.Spec.ComponentDefinition.DeepCopy()
+	case *v1beta1.TraitDefinition:
+		*def = *defRev.Spec.TraitDefinition.DeepCopy()
+	case *v1beta1.PolicyDefinition:
+		*def = *defRev.Spec.PolicyDefinition.DeepCopy()
+	case *v1beta1.WorkflowStepDefinition:
+		*def = *defRev.Spec.WorkflowStepDefinition.DeepCopy()
 	}
 	return nil
 }
@@ -269,59 +589,145 @@ func getDefinitionType(definition client.Object) (common.DefinitionType, error)
 	return definitionType, nil
 }
 
+// NewDefinitionObject creates an empty typed definition object for the given DefinitionType, it is the reverse of
+// getDefinitionType and can be used to allocate the right type before calling GetDefinition.
+func NewDefinitionObject(definitionType common.DefinitionType) (client.Object, error) {
+	switch definitionType {
+	case common.ComponentType:
+		return &v1beta1.ComponentDefinition{}, nil
+	case common.TraitType:
+		return &v1beta1.TraitDefinition{}, nil
+	case common.PolicyType:
+		return &v1beta1.PolicyDefinition{}, nil
+	case common.WorkflowStepType:
+		return &v1beta1.WorkflowStepDefinition{}, nil
+	default:
+		return nil, fmt.Errorf("invalid definition type %q", definitionType)
+	}
+}
+
 func fetchDefinitionRevision(ctx context.Context, cli client.Reader, definitionName string, definitionType common.DefinitionType, annotations map[string]string) (bool, *v1beta1.DefinitionRevision, error) {
 	// if the component's type doesn't contain '@' means user want to use the latest Definition.
-	if !strings.Contains(definitionName, "@") {
+	if !IsPinnedDefinitionReference(definitionName) {
 		return true, nil, nil
 	}
 
-	defRevName, err := ConvertDefinitionRevName(definitionName)
+	defVersion, err := ParseDefinitionVersion(definitionName)
 	if err != nil {
 		return false, nil, err
 	}
+	defName := defVersion.Name
+	switch defVersion.Type {
+	case DefinitionVersionConstraint:
+		latestRevisionName, err := getLatestDefinitionRevisionNameWithTrace(ctx, cli, defName, defVersion.Version, definitionType)
+		if err != nil {
+			return false, nil, err
+		}
+		defRev, err := getDefinitionRevisionWithTrace(ctx, cli, latestRevisionName)
+		if err != nil {
+			return false, nil, err
+		}
+		return false, defRev, nil
+	case DefinitionVersionChannel:
+		return false, nil, errors.Errorf("cannot resolve channel %s of definition %s", defVersion.Version, defName)
+	default:
+	}
 
-	defName := strings.Split(definitionName, "@")[0]
+	defRevName := defVersion.RevisionName
 	autoUpdate, ok := annotations[oam.AnnotationAutoUpdate]
 	if ok && autoUpdate == "true" {
-		latestRevisionName, err := GetLatestDefinitionRevisionName(ctx, cli.(client.Client), defName, defRevName, definitionType)
+		trace.SpanFromContext(ctx).SetAttributes(AttributeDefinitionAutoUpdate.Bool(true))
+		latestRevisionName, err := getLatestDefinitionRevisionNameWithTrace(ctx, cli, defName, defRevName, definitionType)
 		if err != nil {
 			return false, nil, err
 		}
 		defRevName = latestRevisionName
 	}
 
-	defRev := new(v1beta1.DefinitionRevision)
-	if err := GetDefinition(ctx, cli, defRev, defRevName); err != nil {
+	defRev, err := getDefinitionRevisionWithTrace(ctx, cli, defRevName)
+	if err != nil {
 		return false, nil, err
 	}
 
 	return false, defRev, nil
 }
 
+func getLatestDefinitionRevisionNameWithTrace(ctx context.Context, cli client.Reader, definitionName, revisionName string, definitionType common.DefinitionType) (latestRevisionName string, err error) {
+	err = traceClientCall(ctx, "GetLatestDefinitionRevisionName", func(ctx context.Context) error {
+		if latestRevisionName, err = findLatestDefinitionRevisionName(ctx, cli, definitionName, revisionName, definitionType); err != nil {
+			return err
+		}
+		if latestRevisionName == "" {
+			// report the miss as not found, so that it can be told apart from the failures by IsDefinitionNotFound
+			return apierrors.NewNotFound(v1beta1.SchemeGroupVersion.WithResource("definitionrevisions").GroupResource(),
+				fmt.Sprintf("%s(%s)", definitionName, revisionName))
+		}
+		return nil
+	}, AttributeDefinitionName.String(definitionName), AttributeDefinitionType.String(string(definitionType)))
+	return latestRevisionName, err
+}
+
+func getDefinitionRevisionWithTrace(ctx context.Context, cli client.Reader, defRevName string) (*v1beta1.DefinitionRevision, error) {
+	defRev := new(v1beta1.DefinitionRevision)
+	err := traceClientCall(ctx, "GetDefinitionRevision", func(ctx context.Context) error {
+		return GetDefinition(ctx, cli, defRev, defRevName)
+	}, AttributeDefinitionName.String(defRevName))
+	if err != nil {
+		return nil, err
+	}
+	return defRev, nil
+}
+
 // GetLatestDefinitionRevisionName returns the latest definition revision name in specified version range.
-func GetLatestDefinitionRevisionName(ctx context.Context, cli client.Client, definitionName, revisionName string, definitionType common.DefinitionType) (string, error) {
-	for _, ns := range []string{GetDefinitionNamespaceWithCtx(ctx), oam.SystemDefinitionNamespace} {
+// The revisionName can either be a DefinitionRevision name like `worker-v1.2`, which matches all the revisions
+// with version 1.2.x including the prereleases like 1.2.3-beta.1 for compatibility, or a semver constraint like
+// `>=1.2.0 <2.0.0` or `^1.3`, which only matches the prereleases if the constraint has a prerelease itself.
+// An error is returned directly if the constraint is invalid. The revisions are searched in the app namespace, x-definition namespace and system namespace in order like GetDefinition.
+func GetLatestDefinitionRevisionName(ctx context.Context, cli client.Reader, definitionName, revisionName string, definitionType common.DefinitionType) (string, error) {
+	latestRevisionName, err := findLatestDefinitionRevisionName(ctx, cli, definitionName, revisionName, definitionType)
+	if err != nil {
+		return "", err
+	}
+	if latestRevisionName == "" {
+		return "", fmt.Errorf("error finding definition revision for Name: %v, Type: %v", definitionName, definitionType)
+	}
+	return latestRevisionName, nil
+}
 
+// findLatestDefinitionRevisionName finds the latest definition revision name like GetLatestDefinitionRevisionName,
+// but returns an empty name without error if nothing matches
+func findLatestDefinitionRevisionName(ctx context.Context, cli client.Reader, definitionName, revisionName string, definitionType common.DefinitionType) (string, error) {
+	for _, ns := range definitionNamespacesWithCtx(ctx) {
 		revisionListForDefinition, err := fetchAllRevisionsForDefinitionName(ctx, cli, ns, definitionName, definitionType)
 		if err != nil {
 			return "", err
 		}
 
 		matchedDefinitionRevision, err := getMatchingDefinitionRevision(revisionName, definitionName, revisionListForDefinition, definitionType)
-		if err == nil && matchedDefinitionRevision != "" {
+		if err != nil {
+			return "", err
+		}
+		if matchedDefinitionRevision != "" {
 			return matchedDefinitionRevision, nil
 		}
 	}
-	return "", fmt.Errorf("error finding definition revision for Name: %v, Type: %v", definitionName, definitionType)
+	return "", nil
+}
 
+func fetchAllRevisionsForDefinitionName(ctx context.Context, cli client.Reader, ns, definitionName string, definitionType common.DefinitionType) (*v1beta1.DefinitionRevisionList, error) {
+	if cache := getDefinitionRevisionListCacheWithCtx(ctx); cache != nil {
+		return cache.list(ctx, cli, ns, definitionName, definitionType)
+	}
+	return listAllRevisionsForDefinitionName(ctx, cli, ns, definitionName, definitionType)
 }
 
-func fetchAllRevisionsForDefinitionName(ctx context.Context, cli client.Client, ns, definitionName string, definitionType common.DefinitionType) (*v1beta1.DefinitionRevisionList, error) {
+func listAllRevisionsForDefinitionName(ctx context.Context, cli client.Reader, ns, definitionName string, definitionType common.DefinitionType) (*v1beta1.DefinitionRevisionList, error) {
+	selector, err := DefinitionRevisionSelector(definitionName, definitionType)
+	if err != nil {
+		return nil, err
+	}
 	var listOptions []client.ListOption
-	listOptions = append(listOptions, client.InNamespace(ns),
-		client.MatchingLabels{
-			DefinitionKindToNameLabel[definitionType]: definitionName,
-		})
+	listOptions = append(listOptions, client.InNamespace(ns), client.MatchingLabelsSelector{Selector: selector})
 
 	revisionList := v1beta1.DefinitionRevisionList{}
 	revisionList.SetGroupVersionKind(schema.GroupVersionKind{
@@ -330,14 +736,37 @@ func fetchAllRevisionsForDefinitionName(ctx context.Context, cli client.Client,
 		Kind:    v1beta1.DefinitionRevisionKind,
 	})
 
-	err := cli.List(ctx, &revisionList, listOptions...)
+	err = cli.List(ctx, &revisionList, listOptions...)
 
 	return &revisionList, err
 }
 
+// NormalizeVersion normalizes the version string into the canonical semver form without the leading `v`,
+// e.g. v1.2.3 and 1.2.3 are both normalized into 1.2.3, and v1.2 is normalized into 1.2.0. The build
+// metadata like 1.2.3+build is kept, which is ignored when comparing the versions.
+func NormalizeVersion(s string) (string, error) {
+	v, err := parseNormalizedVersion(s)
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+func parseNormalizedVersion(s string) (*semver.Version, error) {
+	trimmed := strings.TrimPrefix(s, "v")
+	// semver accepts the leading `v` as well, so only one leading `v` is allowed here
+	if trimmed == "" || strings.HasPrefix(trimmed, "v") {
+		return nil, errors.Errorf("invalid version %q", s)
+	}
+	v, err := semver.NewVersion(trimmed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid version %q", s)
+	}
+	return v, nil
+}
+
 func getMatchingDefinitionRevision(exactRevisionName, definitionName string, revisionList *v1beta1.DefinitionRevisionList, definitionType common.DefinitionType) (string, error) {
 	var definitionVersions []*semver.Version
-	revisionPrefix := exactRevisionName + "."
 	orignalVersions := make(map[string]string)
 
 	for _, revision := range revisionList.Items {
@@ -347,14 +776,29 @@ func getMatchingDefinitionRevision(exactRevisionName, definitionName string, rev
 		if revision.Name == exactRevisionName {
 			return exactRevisionName, nil
 		}
+	}
+
+	constraint, partial, err := parseRevisionConstraint(exactRevisionName, definitionName)
+	if err != nil {
+		return "", err
+	}
+	for _, revision := range revisionList.Items {
+		if definitionType != "" && definitionType != revision.Spec.DefinitionType {
+			continue
+		}
+		if !strings.HasPrefix(revision.Name, definitionName+"-") {
+			continue
+		}
+		version := strings.TrimPrefix(revision.Name, definitionName+"-")
+		v, err := parseNormalizedVersion(version)
+		if err != nil {
+			// skip the revisions whose name is not a semver, e.g. worker-vbad
+			klog.V(oam.LogDebug).InfoS("Skip the malformed DefinitionRevision name", "revision", revision.Name, "err", err)
+			continue
+		}
+		orignalVersions[v.String()] = version
 		// Only get the revisions that the user expects
-		if strings.HasPrefix(revision.Name, revisionPrefix) {
-			version := strings.Split(revision.Name, definitionName+"-")[1]
-			v, err := semver.NewVersion(version)
-			orignalVersions[v.String()] = version
-			if err != nil {
-				return "", err
-			}
+		if constraint.Check(v) || (partial && v.Prerelease() != "" && constraint.Check(releaseVersion(v))) {
 			definitionVersions = append(definitionVersions, v)
 		}
 	}
@@ -366,31 +810,229 @@ func getMatchingDefinitionRevision(exactRevisionName, definitionName string, rev
 	return definitionName + "-" + orignalVersions[latestVersion.String()], nil
 }
 
+// parseRevisionConstraint builds the semver constraint from a DefinitionRevision name like `worker-v1.2`
+// or from a raw constraint like `>=1.2.0 <2.0.0`. It also reports whether the constraint is built from a partial
+// version in revision name, which should match the prereleases as well.
+func parseRevisionConstraint(revisionName, definitionName string) (constraint *semver.Constraints, partial bool, err error) {
+	version := revisionName
+	if strings.HasPrefix(revisionName, definitionName+"-") {
+		version = strings.TrimPrefix(revisionName, definitionName+"-")
+		// a partial version in revision name matches all the revisions with the same prefix, e.g. v1.2 matches v1.2.x
+		if strings.Count(version, ".") < 2 {
+			version += ".x"
+			partial = true
+		}
+	}
+	constraint, err = semver.NewConstraint(version)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "invalid version constraint %q for definition %s", version, definitionName)
+	}
+	return constraint, partial, nil
+}
+
+// releaseVersion returns the version without the prerelease and metadata, e.g. 1.2.3 for 1.2.3-beta.1
+func releaseVersion(v *semver.Version) *semver.Version {
+	return semver.New(v.Major(), v.Minor(), v.Patch(), "", "")
+}
+
+// DefinitionVersionType describes how the version of a definition is specified in Application
+type DefinitionVersionType string
+
+const (
+	// DefinitionVersionLatest means no version is specified and the latest definition is used, e.g. worker
+	DefinitionVersionLatest DefinitionVersionType = "Latest"
+	// DefinitionVersionExact means an exact version is pinned, e.g. worker@v1.3.1
+	DefinitionVersionExact DefinitionVersionType = "Exact"
+	// DefinitionVersionConstraint means a semver constraint is specified, e.g. worker@^1.3
+	DefinitionVersionConstraint DefinitionVersionType = "Constraint"
+	// DefinitionVersionChannel means a channel is specified, e.g. worker@stable
+	DefinitionVersionChannel DefinitionVersionType = "Channel"
+)
+
+// exactVersionRegexp matches the version pinned like v1.3.1
+var exactVersionRegexp = regexp.MustCompile(`^v\d`)
+
+// DefinitionVersion is the parsed result of the definition type defined in Application
+type DefinitionVersion struct {
+	// Type is how the version is specified
+	Type DefinitionVersionType
+	// Name is the name of the definition
+	Name string
+	// Version is the version after `@`, it can be an exact version, a semver constraint or a channel
+	Version string
+	// RevisionName is the DefinitionRevision name of the exact version, or the definition name for the latest one
+	RevisionName string
+}
+
+// ParseDefinitionVersion parses the definition type defined in Application, e.g., worker, worker@v1.3.1,
+// worker@^1.3 or worker@stable. The channel must be a DNS label which is not a semver constraint, otherwise
+// the version will be treated as an invalid semver constraint.
+func ParseDefinitionVersion(definitionName string) (*DefinitionVersion, error) {
+	defName, version := SplitDefinitionReference(definitionName)
+	found := IsPinnedDefinitionReference(definitionName)
+	if !found || exactVersionRegexp.MatchString(version) {
+		defRevName, err := ConvertDefinitionRevName(definitionName)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return &DefinitionVersion{Type: DefinitionVersionLatest, Name: defName, RevisionName: defRevName}, nil
+		}
+		return &DefinitionVersion{Type: DefinitionVersionExact, Name: defName, Version: version, RevisionName: defRevName}, nil
+	}
+	if err := ValidateQualifiedName(defName); err != nil {
+		return nil, errors.Wrap(err, "invalid definition name")
+	}
+	_, _, err := parseRevisionConstraint(version, defName)
+	if err == nil {
+		return &DefinitionVersion{Type: DefinitionVersionConstraint, Name: defName, Version: version}, nil
+	}
+	if errs := validation.IsDNS1123Label(version); len(errs) != 0 {
+		return nil, err
+	}
+	return &DefinitionVersion{Type: DefinitionVersionChannel, Name: defName, Version: version}, nil
+}
+
+// IsPinnedDefinitionReference checks if the definition referenced in Application is pinned to a version by `@`,
+// e.g. worker@v1.3.1 or worker@^1.3, otherwise the reference floats to the latest definition.
+func IsPinnedDefinitionReference(definitionName string) bool {
+	return strings.Contains(definitionName, "@")
+}
+
+// SplitDefinitionReference splits the definition referenced in Application on the first `@` into the base name and
+// the revision spec, e.g. worker@v1.3.1 is split into worker and v1.3.1. The revision spec keeps the rest `@` if
+// any, so that the malformed reference like worker@v1@v2 can be detected, and it's empty if there is no `@`.
+func SplitDefinitionReference(name string) (baseName, revisionSpec string) {
+	baseName, revisionSpec, _ = strings.Cut(name, "@")
+	return baseName, revisionSpec
+}
+
+// ParseDefinitionReference parses the definition referenced in Application into the definition name and the
+// version after `@`, and reports whether the reference is pinned. The version is empty for the floating reference.
+// Malformed references, e.g. worker@ or @v1.3.1, are rejected like ParseDefinitionVersion.
+func ParseDefinitionReference(definitionName string) (defName, version string, pinned bool, err error) {
+	defVersion, err := ParseDefinitionVersion(definitionName)
+	if err != nil {
+		return "", "", false, err
+	}
+	return defVersion.Name, defVersion.Version, defVersion.Type != DefinitionVersionLatest, nil
+}
+
+// InvalidRevisionNameReason is the reason why a definition revision name is invalid
+type InvalidRevisionNameReason string
+
+const (
+	// InvalidRevisionNameMalformed means the name cannot be split into a definition name and a version,
+	// e.g. the definition name is empty or the version is specified more than once
+	InvalidRevisionNameMalformed InvalidRevisionNameReason = "Malformed"
+	// InvalidRevisionNameNotQualified means the converted name is not a qualified kubernetes resource name
+	InvalidRevisionNameNotQualified InvalidRevisionNameReason = "NotQualified"
+)
+
+// ErrInvalidRevisionName means the definition type defined in Application cannot be converted to a DefinitionRevision name
+type ErrInvalidRevisionName struct {
+	// Name is the offending name
+	Name string
+	// Reason is the reason why the name is invalid
+	Reason InvalidRevisionNameReason
+	// Errs is the failures of the name validation
+	Errs []string
+	// Err is the error returned by ValidateQualifiedName
+	Err error
+}
+
+// newErrInvalidRevisionName wraps the aggregated error of ValidateQualifiedName into ErrInvalidRevisionName
+func newErrInvalidRevisionName(name string, reason InvalidRevisionNameReason, err error) ErrInvalidRevisionName {
+	e := ErrInvalidRevisionName{Name: name, Reason: reason, Err: err}
+	var agg utilerrors.Aggregate
+	if errors.As(err, &agg) {
+		for _, ae := range agg.Errors() {
+			e.Errs = append(e.Errs, ae.Error())
+		}
+	}
+	return e
+}
+
+func (e ErrInvalidRevisionName) Error() string {
+	return fmt.Sprintf("invalid definitionRevision name %s:%s", e.Name, strings.Join(e.Errs, ","))
+}
+
+// Unwrap returns the error of the name validation
+func (e ErrInvalidRevisionName) Unwrap() error {
+	return e.Err
+}
+
+// IsInvalidRevisionName check if error is ErrInvalidRevisionName
+func IsInvalidRevisionName(err error) bool {
+	var e ErrInvalidRevisionName
+	return errors.As(err, &e)
+}
+
 // ConvertDefinitionRevName can help convert definition type defined in Application to DefinitionRevision Name
-// e.g., worker@v1.3.1 will be convert to worker-v1.3.1
+// e.g., worker@v1.3.1 will be convert to worker-v1.3.1. Use ParseDefinitionVersion for the semver constraints
+// and channels which cannot be converted to a DefinitionRevision name directly.
+// The returned error is an ErrInvalidRevisionName if the name is invalid.
 func ConvertDefinitionRevName(definitionName string) (string, error) {
-	splits := strings.Split(definitionName, "@v")
-	if len(splits) == 1 || len(splits[0]) == 0 {
-		errs := validation.IsQualifiedName(definitionName)
-		if len(errs) != 0 {
-			return definitionName, errors.Errorf("invalid definitionRevision name %s:%s", definitionName, strings.Join(errs, ","))
+	defName, revisionSpec := SplitDefinitionReference(definitionName)
+	if !IsPinnedDefinitionReference(definitionName) || !strings.HasPrefix(revisionSpec, "v") || defName == "" {
+		if err := ValidateQualifiedName(definitionName); err != nil {
+			reason := InvalidRevisionNameNotQualified
+			if defName == "" && strings.HasPrefix(revisionSpec, "v") {
+				reason = InvalidRevisionNameMalformed
+			}
+			return definitionName, newErrInvalidRevisionName(definitionName, reason, err)
 		}
 		return definitionName, nil
 	}
 
-	defName := splits[0]
-	revisionName := strings.TrimPrefix(definitionName, fmt.Sprintf("%s@v", defName))
-	defRevName := fmt.Sprintf("%s-v%s", defName, revisionName)
-	errs := validation.IsQualifiedName(defRevName)
-	if len(errs) != 0 {
-		return defRevName, errors.Errorf("invalid definitionRevision name %s:%s", defName, strings.Join(errs, ","))
+	defRevName := fmt.Sprintf("%s-%s", defName, revisionSpec)
+	if err := ValidateQualifiedName(defRevName); err != nil {
+		reason := InvalidRevisionNameNotQualified
+		if strings.Contains(revisionSpec, "@") {
+			reason = InvalidRevisionNameMalformed
+		}
+		return defRevName, newErrInvalidRevisionName(defName, reason, err)
 	}
 	return defRevName, nil
 }
 
-// when get a namespaced scope object without namespace, would get an error request namespace
-func checkRequestNamespaceError(err error) bool {
-	return err != nil && err.Error() == "an empty namespace may not be set when a resource name is provided"
+// ValidateQualifiedName validates the name against the rules of the kubernetes qualified name, e.g. the name of a
+// definition. All the violations are aggregated into the returned error, which can be inspected by errors.As with
+// utilerrors.Aggregate.
+func ValidateQualifiedName(name string) error {
+	msgs := validation.IsQualifiedName(name)
+	if len(msgs) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(msgs))
+	for _, msg := range msgs {
+		errs = append(errs, errors.New(msg))
+	}
+	return errors.Wrapf(utilerrors.NewAggregate(errs), "name %q is not a qualified name", name)
+}
+
+// emptyNamespaceErrorMessage is the error message of the request which gets a namespaced object without namespace
+const emptyNamespaceErrorMessage = "an empty namespace may not be set when a resource name is provided"
+
+// IsEmptyNamespaceError checks if the error is caused by getting a namespaced scope object without namespace,
+// it can be used to detect whether a resource is namespaced when falling back to the cluster scope. Both the
+// error built by the rest client and the BadRequest StatusError with the same message are detected, and the
+// wrapped errors are unwrapped.
+func IsEmptyNamespaceError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Reason == metav1.StatusReasonBadRequest &&
+		strings.Contains(status.Status().Message, emptyNamespaceErrorMessage) {
+		return true
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if err.Error() == emptyNamespaceErrorMessage {
+			return true
+		}
+	}
+	return false
 }
 
 // EndReconcileWithNegativeCondition is used to handle reconcile failure for a conditioned resource.
@@ -405,6 +1047,11 @@ func EndReconcileWithNegativeCondition(ctx context.Context, r client.StatusClien
 	}
 	workloadPatch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
 	conditionIsChanged := IsConditionChanged(condition, workload)
+	if klog.V(oam.LogDebug).Enabled() {
+		for _, change := range DiffConditions(condition, workload) {
+			klog.InfoS("Condition transitioned", "object", klog.KObj(workload), "change", change.String())
+		}
+	}
 	workload.SetConditions(condition...)
 	if err := r.Status().Patch(ctx, workload, workloadPatch, client.FieldOwner(workload.GetUID())); err != nil {
 		return errors.Wrap(err, ErrUpdateStatus)
@@ -419,39 +1066,272 @@ func EndReconcileWithNegativeCondition(ctx context.Context, r client.StatusClien
 	return errors.Errorf(ErrReconcileErrInCondition, condition[0].Type, condition[0].Message)
 }
 
-// PatchCondition will patch status with condition and return, it generally used by cases which don't want to reconcile after patch
-func PatchCondition(ctx context.Context, r client.StatusClient, workload ConditionedObject,
-	condition ...condition.Condition) error {
-	if len(condition) == 0 {
-		return nil
-	}
-	workloadPatch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
-	workload.SetConditions(condition...)
-	return r.Status().Patch(ctx, workload, workloadPatch, client.FieldOwner(workload.GetUID()))
+// ReconcileBackoff is the capped exponential backoff used by EndReconcileWithNegativeConditionBackoff
+type ReconcileBackoff struct {
+	// Base is the requeue delay of the first failure
+	Base time.Duration
+	// Max is the cap of the requeue delay
+	Max time.Duration
 }
 
-// IsConditionChanged will check if conditions in workload are changed compare to newCondition
-func IsConditionChanged(newCondition []condition.Condition, workload ConditionedObject) bool {
-	var conditionIsChanged bool
+// DefaultReconcileBackoff is the default backoff used when the fields of ReconcileBackoff are not set
+var DefaultReconcileBackoff = ReconcileBackoff{Base: time.Second, Max: 5 * time.Minute}
+
+// Duration returns the requeue delay after the given number of consecutive failures
+func (b ReconcileBackoff) Duration(failures int) time.Duration {
+	base, limit := b.Base, b.Max
+	if base <= 0 {
+		base = DefaultReconcileBackoff.Base
+	}
+	if limit <= 0 {
+		limit = DefaultReconcileBackoff.Max
+	}
+	delay := base
+	for i := 1; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	return MinOf(delay, limit)
+}
+
+// ReconcileFailureTracker tracks the number of consecutive reconcile failures of the resources in memory, it should
+// be held by the reconciler and shared by its workers. The resources are identified by UID, so the count of a
+// recreated resource starts over. Call Forget when the resource is deleted to release its entry.
+type ReconcileFailureTracker struct {
+	mu       sync.Mutex
+	failures map[types.UID]int
+}
+
+// NewReconcileFailureTracker create a ReconcileFailureTracker
+func NewReconcileFailureTracker() *ReconcileFailureTracker {
+	return &ReconcileFailureTracker{failures: map[types.UID]int{}}
+}
+
+// Failed records a failure of the resource and returns the number of its consecutive failures
+func (t *ReconcileFailureTracker) Failed(obj client.Object) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures[obj.GetUID()]++
+	return t.failures[obj.GetUID()]
+}
+
+// Failures returns the number of consecutive failures of the resource
+func (t *ReconcileFailureTracker) Failures(obj client.Object) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.failures[obj.GetUID()]
+}
+
+// Forget resets the failures of the resource
+func (t *ReconcileFailureTracker) Forget(obj client.Object) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, obj.GetUID())
+}
+
+// EndReconcileWithNegativeConditionBackoff is used to handle reconcile failure for a conditioned resource like
+// EndReconcileWithNegativeCondition. Instead of returning an error when no condition is changed, it records the
+// failure in the tracker and requeues the resource after a capped exponential backoff of the consecutive failures.
+// The failures are forgotten once any condition is changed. Only the status is patched, so that the failure doesn't
+// trigger an immediate reconcile through the metadata changes. If the tracker is nil, the failures are not tracked and
+// the resource is always requeued after the fixed base delay of the backoff.
+func EndReconcileWithNegativeConditionBackoff(ctx context.Context, r client.StatusClient, workload ConditionedObject,
+	tracker *ReconcileFailureTracker, backoff ReconcileBackoff, condition ...condition.Condition) (reconcile.Result, error) {
+	if len(condition) == 0 {
+		return reconcile.Result{}, nil
+	}
+	conditionIsChanged := IsConditionChanged(condition, workload)
+	workloadPatch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	workload.SetConditions(condition...)
+	if err := r.Status().Patch(ctx, workload, workloadPatch, client.FieldOwner(workload.GetUID())); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, ErrUpdateStatus)
+	}
+	if tracker == nil {
+		if conditionIsChanged {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{RequeueAfter: backoff.Duration(1)}, nil
+	}
+	if conditionIsChanged {
+		// patching changed conditions can trigger requeue the resource
+		tracker.Forget(workload)
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: backoff.Duration(tracker.Failed(workload))}, nil
+}
+
+// PatchCondition will patch status with condition and return, it generally used by cases which don't want to reconcile after patch
+// The observedGeneration will be recorded if the resource implements ObservedGenerationSetter.
+func PatchCondition(ctx context.Context, r client.StatusClient, workload ConditionedObject,
+	condition ...condition.Condition) error {
+	if len(condition) == 0 {
+		return nil
+	}
+	workloadPatch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	workload.SetConditions(condition...)
+	setObservedGeneration(workload)
+	return r.Status().Patch(ctx, workload, workloadPatch, client.FieldOwner(workload.GetUID()))
+}
+
+// PatchConditionIfChanged patches the conditions like PatchCondition, but skips the patch if neither the conditions
+// nor the observedGeneration would be changed, so that the no-op writes are avoided. It returns whether the patch
+// is issued.
+func PatchConditionIfChanged(ctx context.Context, r client.StatusClient, workload ConditionedObject,
+	condition ...condition.Condition) (bool, error) {
+	if len(condition) == 0 {
+		return false, nil
+	}
+	if !IsConditionChanged(condition, workload) {
+		current, observed := workload.DeepCopyObject(), workload.DeepCopyObject().(ConditionedObject)
+		setObservedGeneration(observed)
+		if reflect.DeepEqual(current, observed) {
+			return false, nil
+		}
+	}
+	return true, PatchCondition(ctx, r, workload, condition...)
+}
+
+// MaxPatchConditionsConcurrent is the max concurrency of the status patches issued by PatchConditions
+var MaxPatchConditionsConcurrent = 10
+
+// PatchConditions patches the same conditions to the status of the objects concurrently, the failure of one object
+// does not abort the others. It returns the aggregated errors of the objects which fail to be patched.
+func PatchConditions(ctx context.Context, r client.StatusClient, objects []ConditionedObject,
+	condition ...condition.Condition) error {
+	errs := velaslices.ParMap(objects, func(obj ConditionedObject) error {
+		if err := PatchCondition(ctx, r, obj, condition...); err != nil {
+			return errors.Wrapf(err, "failed to patch conditions of %T %s", obj, client.ObjectKeyFromObject(obj))
+		}
+		return nil
+	}, velaslices.Parallelism(MaxPatchConditionsConcurrent))
+	return utilerrors.NewAggregate(errs)
+}
+
+// ConditionChangeType is the type of the change of a condition
+type ConditionChangeType string
+
+const (
+	// ConditionAdded means the condition does not exist in the object before
+	ConditionAdded ConditionChangeType = "Added"
+	// ConditionRemoved means the existing condition is not in the new conditions
+	ConditionRemoved ConditionChangeType = "Removed"
+	// ConditionModified means the status, reason or message of the condition is changed
+	ConditionModified ConditionChangeType = "Modified"
+)
+
+// ConditionChange records the transition of a condition
+type ConditionChange struct {
+	Type      condition.ConditionType
+	Change    ConditionChangeType
+	OldStatus corev1.ConditionStatus
+	NewStatus corev1.ConditionStatus
+	OldReason condition.ConditionReason
+	NewReason condition.ConditionReason
+}
+
+// String returns the readable message of the change
+func (c ConditionChange) String() string {
+	return fmt.Sprintf("%s condition %s: %s(%s) -> %s(%s)", c.Change, c.Type, c.OldStatus, c.OldReason, c.NewStatus, c.NewReason)
+}
+
+// MergeConditionsPreservingTransition returns a copy of the incoming conditions whose LastTransitionTime is
+// carried forward from the existing condition of the same type if the status is unchanged, so the timestamp is
+// only updated on a real status transition, e.g. the message-only changes keep the original transition time.
+// Controllers can call it before SetConditions.
+func MergeConditionsPreservingTransition(existing, incoming []condition.Condition) []condition.Condition {
+	if incoming == nil {
+		return nil
+	}
+	merged := make([]condition.Condition, len(incoming))
+	for i, c := range incoming {
+		for _, e := range existing {
+			if e.Type == c.Type && e.Status == c.Status {
+				c.LastTransitionTime = e.LastTransitionTime
+				break
+			}
+		}
+		merged[i] = c
+	}
+	return merged
+}
+
+// conditionsLister is an object which can list all of its conditions
+type conditionsLister interface {
+	GetConditions() []condition.Condition
+}
+
+// DiffConditions compares the new conditions with the ones in workload and returns the changed conditions.
+// The removed conditions can only be found if the workload can list all of its conditions.
+func DiffConditions(newCondition []condition.Condition, workload ConditionedObject) []ConditionChange {
+	var changes []ConditionChange
+	newTypes := map[condition.ConditionType]bool{}
 	for _, newCond := range newCondition {
+		newTypes[newCond.Type] = true
 		// NOTE(roywang) an implicit rule here: condition type is unique in an object's conditions
 		// if this rule is changed in the future, we must revise below logic correspondingly
 		existingCond := workload.GetCondition(newCond.Type)
+		if existingCond.Equal(newCond) {
+			continue
+		}
+		change := ConditionChange{
+			Type:      newCond.Type,
+			Change:    ConditionModified,
+			OldStatus: existingCond.Status,
+			NewStatus: newCond.Status,
+			OldReason: existingCond.Reason,
+			NewReason: newCond.Reason,
+		}
+		if existingCond == (condition.Condition{Type: newCond.Type, Status: corev1.ConditionUnknown}) {
+			change.Change = ConditionAdded
+			change.OldStatus = ""
+		}
+		changes = append(changes, change)
+	}
+	if lister, ok := workload.(conditionsLister); ok {
+		for _, existingCond := range lister.GetConditions() {
+			if !newTypes[existingCond.Type] {
+				changes = append(changes, ConditionChange{
+					Type:      existingCond.Type,
+					Change:    ConditionRemoved,
+					OldStatus: existingCond.Status,
+					OldReason: existingCond.Reason,
+				})
+			}
+		}
+	}
+	return changes
+}
 
-		if !existingCond.Equal(newCond) {
-			conditionIsChanged = true
-			break
+// IsConditionChanged will check if conditions in workload are changed compare to newCondition
+// The existing conditions not in newCondition are not regarded as changed, since SetConditions never removes them.
+func IsConditionChanged(newCondition []condition.Condition, workload ConditionedObject) bool {
+	for _, change := range DiffConditions(newCondition, workload) {
+		if change.Change != ConditionRemoved {
+			return true
 		}
 	}
-	return conditionIsChanged
+	return false
+}
+
+// ObservedGenerationSetter is an object which records the generation observed by the controller in its status
+type ObservedGenerationSetter interface {
+	SetObservedGeneration(generation int64)
+}
+
+// setObservedGeneration sets status.observedGeneration to metadata.generation if the object supports it
+func setObservedGeneration(workload ConditionedObject) {
+	if setter, ok := workload.(ObservedGenerationSetter); ok {
+		setter.SetObservedGeneration(workload.GetGeneration())
+	}
 }
 
 // EndReconcileWithPositiveCondition is used to handle reconcile success for a conditioned resource.
 // It should only accept positive condition which means no need to requeue the resource.
+// The observedGeneration will be recorded if the resource implements ObservedGenerationSetter.
 func EndReconcileWithPositiveCondition(ctx context.Context, r client.StatusClient, workload ConditionedObject,
 	condition ...condition.Condition) error {
 	workloadPatch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
 	workload.SetConditions(condition...)
+	setObservedGeneration(workload)
 	return errors.Wrap(
 		r.Status().Patch(ctx, workload, workloadPatch, client.FieldOwner(workload.GetUID())),
 		ErrUpdateStatus)
@@ -474,6 +1354,61 @@ func PassLabelAndAnnotation(parentObj, childObj labelAnnotationObject) {
 	childObj.SetAnnotations(MergeMapOverrideWithDst(childObj.GetAnnotations(), parentObj.GetAnnotations()))
 }
 
+// KeyFilter filters the keys of labels or annotations. The denied keys and prefixes take precedence over the
+// allowed ones, and all the keys which are not denied are allowed if neither AllowedKeys nor AllowedPrefixes is set.
+type KeyFilter struct {
+	AllowedKeys     []string
+	AllowedPrefixes []string
+	DeniedKeys      []string
+	DeniedPrefixes  []string
+}
+
+// Allowed checks whether the key can pass the filter
+func (f KeyFilter) Allowed(key string) bool {
+	hasPrefix := func(prefixes []string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	if slices.Contains(f.DeniedKeys, key) || hasPrefix(f.DeniedPrefixes) {
+		return false
+	}
+	if len(f.AllowedKeys) == 0 && len(f.AllowedPrefixes) == 0 {
+		return true
+	}
+	return slices.Contains(f.AllowedKeys, key) || hasPrefix(f.AllowedPrefixes)
+}
+
+// Filter returns a new map which only contains the allowed keys
+func (f KeyFilter) Filter(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	r := make(map[string]string, len(m))
+	for k, v := range m {
+		if f.Allowed(k) {
+			r[k] = v
+		}
+	}
+	return r
+}
+
+// PassLabelAndAnnotationOptions is the options for PassLabelAndAnnotationFiltered
+type PassLabelAndAnnotationOptions struct {
+	Labels      KeyFilter
+	Annotations KeyFilter
+}
+
+// PassLabelAndAnnotationFiltered passes through the labels and annotations allowed by the filters from the parent
+// to the child object, when annotation or labels has conflicts, the parentObj will override the childObj.
+func PassLabelAndAnnotationFiltered(parentObj, childObj labelAnnotationObject, opts PassLabelAndAnnotationOptions) {
+	childObj.SetLabels(MergeMapOverrideWithDst(childObj.GetLabels(), opts.Labels.Filter(parentObj.GetLabels())))
+	childObj.SetAnnotations(MergeMapOverrideWithDst(childObj.GetAnnotations(), opts.Annotations.Filter(parentObj.GetAnnotations())))
+}
+
 // RemoveLabels removes keys that contains in the removekeys slice from the label
 func RemoveLabels(o labelAnnotationObject, removeKeys []string) {
 	exist := o.GetLabels()
@@ -492,8 +1427,83 @@ func RemoveAnnotations(o labelAnnotationObject, removeKeys []string) {
 	o.SetAnnotations(exist)
 }
 
+// RemoveLabelsByPrefix removes the labels whose key has any of the prefixes
+func RemoveLabelsByPrefix(o labelAnnotationObject, prefixes []string) {
+	o.SetLabels(removeKeysByPrefix(o.GetLabels(), prefixes))
+}
+
+// RemoveAnnotationsByPrefix removes the annotations whose key has any of the prefixes
+func RemoveAnnotationsByPrefix(o labelAnnotationObject, prefixes []string) {
+	o.SetAnnotations(removeKeysByPrefix(o.GetAnnotations(), prefixes))
+}
+
+func removeKeysByPrefix(m map[string]string, prefixes []string) map[string]string {
+	for key := range m {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(m, key)
+				break
+			}
+		}
+	}
+	return m
+}
+
+// OAMInternalLabelPrefixes are the prefixes of the labels used by the controllers internally, e.g. the app name
+// and the revision hash, which should not be propagated to the managed clusters
+var OAMInternalLabelPrefixes = []string{
+	"app.oam.dev/",
+	"controller.oam.dev/",
+	"workload.oam.dev/",
+	"trait.oam.dev/",
+}
+
+// OAMInternalAnnotationPrefixes are the prefixes of the annotations used by the controllers internally
+var OAMInternalAnnotationPrefixes = []string{
+	"app.oam.dev/",
+	"controller.core.oam.dev/",
+}
+
+// SanitizeOAMMetadata removes the internal labels and annotations of OAM from the object before it is applied to
+// the managed clusters, so the target doesn't inherit the scheduling and ownership metadata. The removed keys are
+// the ones matching OAMInternalLabelPrefixes and OAMInternalAnnotationPrefixes.
+func SanitizeOAMMetadata(o labelAnnotationObject) {
+	RemoveLabelsByPrefix(o, OAMInternalLabelPrefixes)
+	RemoveAnnotationsByPrefix(o, OAMInternalAnnotationPrefixes)
+}
+
+// CopyLabels returns a new map containing the labels of the given keys, all the labels will be copied if no key
+// is specified. The returned map is independent of the object, so it is safe to be modified or attached to others.
+func CopyLabels(o labelAnnotationObject, keys ...string) map[string]string {
+	return copyMapWithKeys(o.GetLabels(), keys)
+}
+
+// CopyAnnotations returns a new map containing the annotations of the given keys, all the annotations will be
+// copied if no key is specified. The returned map is independent of the object.
+func CopyAnnotations(o labelAnnotationObject, keys ...string) map[string]string {
+	return copyMapWithKeys(o.GetAnnotations(), keys)
+}
+
+func copyMapWithKeys(m map[string]string, keys []string) map[string]string {
+	if len(keys) == 0 {
+		r := make(map[string]string, len(m))
+		for k, v := range m {
+			r[k] = v
+		}
+		return r
+	}
+	r := make(map[string]string, len(keys))
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			r[k] = v
+		}
+	}
+	return r
+}
+
 // GetDefinitionName return the Definition name of any resources
-// the format of the definition of a resource is <kind plurals>.<group>
+// the format of the definition of a resource is <kind plurals>.<group>, and it is <kind plurals> for the core resources
+// whose group is empty, which is consistent with GetGVKFromDefinition and ConvertWorkloadGVK2Definition
 // Now the definition name of a resource could also be defined as `definition.oam.dev/name` in `metadata.annotations`
 // typeLabel specified which Definition it is, if specified, will directly get definition from label.
 func GetDefinitionName(mapper meta.RESTMapper, u *unstructured.Unstructured, typeLabel string) (string, error) {
@@ -512,14 +1522,27 @@ func GetDefinitionName(mapper meta.RESTMapper, u *unstructured.Unstructured, typ
 	if err != nil {
 		return "", err
 	}
-	return mapping.Resource.Resource + "." + groupVersion.Group, nil
+	return schema.GroupResource{Group: groupVersion.Group, Resource: mapping.Resource.Resource}.String(), nil
+}
+
+// IsDummyTraitType checks whether the trait type is empty or dummy, which means the trait has no definition
+func IsDummyTraitType(traitType string) bool {
+	return traitType == "" || traitType == Dummy
+}
+
+// IsDummyDefinitionRef checks whether the definitionRef is empty or refers to the dummy definition
+func IsDummyDefinitionRef(ref common.DefinitionReference) bool {
+	return len(ref.Name) < 1 || ref.Name == Dummy
 }
 
 // GetGVKFromDefinition help get Group Version Kind from DefinitionReference
-func GetGVKFromDefinition(mapper meta.RESTMapper, definitionRef common.DefinitionReference) (metav1.GroupVersionKind, error) {
+// If the resource is mapped to kinds of different groups, the kinds in the first matched preferredGroups are used,
+// otherwise a meta.AmbiguousResourceError listing all the candidates will be returned. The reference without group
+// like `services` prefers the core group if no preferredGroups is given, the same as DefinitionNameToGVK.
+func GetGVKFromDefinition(mapper meta.RESTMapper, definitionRef common.DefinitionReference, preferredGroups ...string) (metav1.GroupVersionKind, error) {
 	// if given definitionRef is empty or it's a dummy definition, return an empty GVK
 	// NOTE currently, only TraitDefinition is allowed to omit definitionRef conditionally.
-	if len(definitionRef.Name) < 1 || definitionRef.Name == Dummy {
+	if IsDummyDefinitionRef(definitionRef) {
 		return metav1.GroupVersionKind{}, nil
 	}
 	var gvk metav1.GroupVersionKind
@@ -534,6 +1557,14 @@ func GetGVKFromDefinition(mapper meta.RESTMapper, definitionRef common.Definitio
 			PartialResource: gvr,
 		}
 	}
+	if groupResource.Group == "" && len(preferredGroups) == 0 {
+		// the reference without group like `services` prefers the core resource
+		preferredGroups = []string{""}
+	}
+	kinds, err = disambiguateKinds(gvr, kinds, preferredGroups)
+	if err != nil {
+		return gvk, err
+	}
 	return metav1.GroupVersionKind{
 		Group:   kinds[0].Group,
 		Kind:    kinds[0].Kind,
@@ -541,6 +1572,117 @@ func GetGVKFromDefinition(mapper meta.RESTMapper, definitionRef common.Definitio
 	}, nil
 }
 
+// CanonicalizeDefinitionReference normalizes the DefinitionReference into a consistent form, so the hashing and
+// comparison of the references are stable. The name is trimmed and lowercased, and the version suffix in the name
+// like deployments.apps/v1 is moved into the version. An error will be returned if the versions conflict.
+// The empty or dummy reference is returned as it is.
+func CanonicalizeDefinitionReference(ref common.DefinitionReference) (common.DefinitionReference, error) {
+	if IsDummyDefinitionRef(ref) {
+		return ref, nil
+	}
+	name := strings.ToLower(strings.TrimSpace(ref.Name))
+	version := strings.ToLower(strings.TrimSpace(ref.Version))
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		nameVersion := name[idx+1:]
+		name = name[:idx]
+		if version != "" && nameVersion != version {
+			return ref, errors.Errorf("conflicting versions %q and %q in definition reference %s", nameVersion, version, ref.Name)
+		}
+		version = nameVersion
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return ref, errors.Errorf("invalid definition reference %s", ref.Name)
+	}
+	return common.DefinitionReference{Name: name, Version: version}, nil
+}
+
+// CanonicalizeDefinitionReferenceWithMapper normalizes the DefinitionReference like CanonicalizeDefinitionReference,
+// and then resolves it by the mapper, so the name is in the format of GetDefinitionName and the version is filled
+// with the preferred one if not specified.
+func CanonicalizeDefinitionReferenceWithMapper(mapper meta.RESTMapper, ref common.DefinitionReference) (common.DefinitionReference, error) {
+	canonical, err := CanonicalizeDefinitionReference(ref)
+	if err != nil || IsDummyDefinitionRef(canonical) {
+		return canonical, err
+	}
+	gvk, err := GetGVKFromDefinition(mapper, canonical)
+	if err != nil {
+		return ref, err
+	}
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: gvk.Group, Kind: gvk.Kind}, gvk.Version)
+	if err != nil {
+		return ref, err
+	}
+	return common.DefinitionReference{Name: mapping.Resource.GroupResource().String(), Version: gvk.Version}, nil
+}
+
+// DefinitionNameToGVK resolves the GVK of the resource from its definition name in the format of <kind plurals>.<group>,
+// e.g. deployments.apps or services for core resources. The core group is preferred if the group is empty.
+// A meta.NoResourceMatchError will be returned if no kind matches.
+func DefinitionNameToGVK(mapper meta.RESTMapper, definitionName, version string) (schema.GroupVersionKind, error) {
+	groupResource := schema.ParseGroupResource(definitionName)
+	gvr := groupResource.WithVersion(version)
+	if groupResource.Resource == "" {
+		return schema.GroupVersionKind{}, &meta.NoResourceMatchError{PartialResource: gvr}
+	}
+	var preferredGroups []string
+	if groupResource.Group == "" {
+		preferredGroups = append(preferredGroups, "")
+	}
+	kinds, err := mapper.KindsFor(gvr)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	if len(kinds) < 1 {
+		return schema.GroupVersionKind{}, &meta.NoResourceMatchError{PartialResource: gvr}
+	}
+	if kinds, err = disambiguateKinds(gvr, kinds, preferredGroups); err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	return kinds[0], nil
+}
+
+// IsNamespacedGVK checks if the resource of the GVK is namespaced by the scope of its RESTMapping, it can be used
+// to avoid setting namespace on the cluster scoped resources. All the versions are considered if the version is empty.
+func IsNamespacedGVK(mapper meta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	var versions []string
+	if gvk.Version != "" {
+		versions = append(versions, gvk.Version)
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), versions...)
+	if err != nil {
+		return false, err
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// disambiguateKinds returns the kinds if they share the same GroupKind, the kinds of different versions are
+// not ambiguous since they are ordered by the priority. Otherwise the kinds in preferred groups are returned.
+func disambiguateKinds(gvr schema.GroupVersionResource, kinds []schema.GroupVersionKind, preferredGroups []string) ([]schema.GroupVersionKind, error) {
+	isAmbiguous := func(kinds []schema.GroupVersionKind) bool {
+		for _, kind := range kinds[1:] {
+			if kind.GroupKind() != kinds[0].GroupKind() {
+				return true
+			}
+		}
+		return false
+	}
+	if !isAmbiguous(kinds) {
+		return kinds, nil
+	}
+	for _, group := range preferredGroups {
+		var preferred []schema.GroupVersionKind
+		for _, kind := range kinds {
+			if kind.Group == group {
+				preferred = append(preferred, kind)
+			}
+		}
+		if len(preferred) > 0 && !isAmbiguous(preferred) {
+			return preferred, nil
+		}
+	}
+	return nil, &meta.AmbiguousResourceError{PartialResource: gvr, MatchingKinds: kinds}
+}
+
 // ConvertWorkloadGVK2Definition help convert a GVK to DefinitionReference
 func ConvertWorkloadGVK2Definition(mapper meta.RESTMapper, def common.WorkloadGVK) (common.DefinitionReference, error) {
 	var reference common.DefinitionReference
@@ -559,9 +1701,63 @@ func ConvertWorkloadGVK2Definition(mapper meta.RESTMapper, def common.WorkloadGV
 	return reference, nil
 }
 
-// GetObjectsGivenGVKAndLabels fetches the kubernetes object given its gvk and labels by list API
+// staleListRead is the list option which sets ResourceVersion=0
+type staleListRead struct{}
+
+// ApplyToList sets the ResourceVersion of the list request to 0
+func (staleListRead) ApplyToList(opts *client.ListOptions) {
+	if opts.Raw == nil {
+		opts.Raw = &metav1.ListOptions{}
+	}
+	opts.Raw.ResourceVersion = "0"
+}
+
+// WithStaleListRead returns the list option which sets ResourceVersion=0 on the list request, so that the apiserver
+// serves it from the watch cache rather than etcd. The result may be slightly stale, so it should only be used when
+// the consistent read is not required. It takes no effect on the lists served by the informer cache of the client.
+func WithStaleListRead() client.ListOption {
+	return staleListRead{}
+}
+
+// GetObjectsGivenGVKAndLabels fetches the kubernetes object given its gvk and labels by list API. The extra list
+// options like WithStaleListRead are applied after the labels and the namespace.
 func GetObjectsGivenGVKAndLabels(ctx context.Context, cli client.Reader,
-	gvk schema.GroupVersionKind, namespace string, labels map[string]string) (*unstructured.UnstructuredList, error) {
+	gvk schema.GroupVersionKind, namespace string, labels map[string]string, opts ...client.ListOption) (*unstructured.UnstructuredList, error) {
+	listOpts := append([]client.ListOption{client.MatchingLabels(labels), client.InNamespace(namespace)}, opts...)
+	unstructuredObjList, err := listObjectsGivenGVK(ctx, cli, gvk, listOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to get obj with labels %+v and gvk %+v ", labels, gvk))
+	}
+	return unstructuredObjList, nil
+}
+
+// GetObjectsGivenGVKAndLabelsAllNamespaces fetches the kubernetes object given its gvk and labels in all namespaces
+// by list API, e.g. to garbage collect the resources with OAM labels cluster-wide. Note that it requires the
+// permission to list the resources at the cluster scope, i.e. a ClusterRole rather than a namespaced Role, and the
+// informer cache of the client should not be restricted to some namespaces.
+func GetObjectsGivenGVKAndLabelsAllNamespaces(ctx context.Context, cli client.Reader,
+	gvk schema.GroupVersionKind, labels map[string]string) (*unstructured.UnstructuredList, error) {
+	unstructuredObjList, err := listObjectsGivenGVK(ctx, cli, gvk, client.MatchingLabels(labels))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to get obj with labels %+v and gvk %+v in all namespaces ", labels, gvk))
+	}
+	return unstructuredObjList, nil
+}
+
+// GetObjectsGivenGVKLabelsAndFields fetches the kubernetes object given its gvk, labels and fields by list API,
+// e.g. fields `spec.nodeName=node-1`. Note that filtering by fields other than the metadata ones requires the
+// field index to be registered on the cache through the FieldIndexer of the manager, otherwise the list will fail.
+func GetObjectsGivenGVKLabelsAndFields(ctx context.Context, cli client.Reader,
+	gvk schema.GroupVersionKind, namespace string, labels map[string]string, fields map[string]string) (*unstructured.UnstructuredList, error) {
+	unstructuredObjList, err := listObjectsGivenGVK(ctx, cli, gvk,
+		client.MatchingLabels(labels), client.MatchingFields(fields), client.InNamespace(namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to get obj with labels %+v, fields %+v and gvk %+v ", labels, fields, gvk))
+	}
+	return unstructuredObjList, nil
+}
+
+func listObjectsGivenGVK(ctx context.Context, cli client.Reader, gvk schema.GroupVersionKind, opts ...client.ListOption) (*unstructured.UnstructuredList, error) {
 	unstructuredObjList := &unstructured.UnstructuredList{}
 	apiVersion := metav1.GroupVersion{
 		Group:   gvk.Group,
@@ -569,8 +1765,8 @@ func GetObjectsGivenGVKAndLabels(ctx context.Context, cli client.Reader,
 	}.String()
 	unstructuredObjList.SetAPIVersion(apiVersion)
 	unstructuredObjList.SetKind(gvk.Kind)
-	if err := cli.List(ctx, unstructuredObjList, client.MatchingLabels(labels), client.InNamespace(namespace)); err != nil {
-		return nil, errors.Wrap(err, fmt.Sprintf("failed to get obj with labels %+v and gvk %+v ", labels, gvk))
+	if err := cli.List(ctx, unstructuredObjList, opts...); err != nil {
+		return nil, err
 	}
 	return unstructuredObjList, nil
 }
@@ -595,6 +1791,35 @@ func GetObjectGivenGVKAndName(ctx context.Context, client client.Reader,
 	return obj, nil
 }
 
+// GetObjectGivenGVKAndNameTyped fetches the kubernetes object given its gvk and name like GetObjectGivenGVKAndName,
+// but the api status errors like NotFound and Forbidden are returned as is, so that the callers can inspect the status
+// code of the returned error directly. Other errors are still annotated with the object.
+func GetObjectGivenGVKAndNameTyped(ctx context.Context, cli client.Reader,
+	gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error) {
+	obj, err := GetObjectGivenGVKAndName(ctx, cli, gvk, namespace, name)
+	if err != nil {
+		var statusErr *apierrors.StatusError
+		if errors.As(err, &statusErr) {
+			return nil, statusErr
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+// ResourceKey returns the canonical identity key of the object, which consists of its GroupVersionKind, namespace and
+// name. The GroupVersionKind is read from the object, so the typed objects should have their TypeMeta set.
+func ResourceKey(obj client.Object) string {
+	return ResourceKeyFromGVK(obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+}
+
+// ResourceKeyFromGVK returns the canonical identity key of the resource like `apps/v1/Deployment/default/web`, the
+// group is empty for the core resources and the namespace is empty for the cluster scoped resources. None of the
+// parts contains `/`, so the keys of different resources never collide.
+func ResourceKeyFromGVK(gvk schema.GroupVersionKind, namespace, name string) string {
+	return strings.Join([]string{gvk.Group, gvk.Version, gvk.Kind, namespace, name}, "/")
+}
+
 // Object2Unstructured converts an object to an unstructured struct
 func Object2Unstructured(obj interface{}) (*unstructured.Unstructured, error) {
 	objMap, err := Object2Map(obj)
@@ -606,6 +1831,20 @@ func Object2Unstructured(obj interface{}) (*unstructured.Unstructured, error) {
 	}, nil
 }
 
+// Object2UnstructuredWithGVK converts an object to an unstructured struct and sets its apiVersion and kind explicitly,
+// since the TypeMeta of typed objects is usually empty and omitted in the marshalled object
+func Object2UnstructuredWithGVK(obj interface{}, gvk schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	u, err := Object2Unstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	if u.Object == nil {
+		u.Object = map[string]interface{}{}
+	}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
+
 // RawExtension2Unstructured converts a rawExtension to an unstructured struct
 func RawExtension2Unstructured(raw *runtime.RawExtension) (*unstructured.Unstructured, error) {
 	var objMap map[string]interface{}
@@ -618,14 +1857,43 @@ func RawExtension2Unstructured(raw *runtime.RawExtension) (*unstructured.Unstruc
 	}, nil
 }
 
+// RawExtensions2UnstructuredList converts a rawExtension which contains either a single object or an array of objects
+// to a list of unstructured structs
+func RawExtensions2UnstructuredList(raw *runtime.RawExtension) ([]*unstructured.Unstructured, error) {
+	if raw == nil || len(raw.Raw) == 0 {
+		return nil, nil
+	}
+	data := bytes.TrimSpace(raw.Raw)
+	switch {
+	case bytes.HasPrefix(data, []byte("{")):
+		u, err := RawExtension2Unstructured(&runtime.RawExtension{Raw: data})
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid object in rawExtension")
+		}
+		return []*unstructured.Unstructured{u}, nil
+	case bytes.HasPrefix(data, []byte("[")):
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, errors.Wrap(err, "invalid array in rawExtension")
+		}
+		us := make([]*unstructured.Unstructured, 0, len(items))
+		for i, item := range items {
+			var objMap map[string]interface{}
+			if err := json.Unmarshal(item, &objMap); err != nil || objMap == nil {
+				return nil, errors.Errorf("the item %d in rawExtension array is not an object: %s", i, string(item))
+			}
+			us = append(us, &unstructured.Unstructured{Object: objMap})
+		}
+		return us, nil
+	default:
+		return nil, errors.Errorf("rawExtension is neither an object nor an array: %s", string(data))
+	}
+}
+
 // RawExtension2Application converts runtime.RawExtension to Application
 func RawExtension2Application(raw runtime.RawExtension) (*v1beta1.Application, error) {
 	a := &v1beta1.Application{}
-	b, err := raw.MarshalJSON()
-	if err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal(b, a); err != nil {
+	if err := RawExtension2Object(raw, a); err != nil {
 		return nil, err
 	}
 	if len(a.GetNamespace()) == 0 {
@@ -634,6 +1902,25 @@ func RawExtension2Application(raw runtime.RawExtension) (*v1beta1.Application, e
 	return a, nil
 }
 
+// RawExtension2Object decodes the runtime.RawExtension into the given typed object, the raw extension without any
+// content is a no-op and leaves the object untouched. The embedded Object is used if the Raw bytes are not set.
+func RawExtension2Object(raw runtime.RawExtension, into runtime.Object) error {
+	data := raw.Raw
+	if len(data) == 0 {
+		if raw.Object == nil {
+			return nil
+		}
+		var err error
+		if data, err = json.Marshal(raw.Object); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, into); err != nil {
+		return errors.Wrapf(err, "failed to decode rawExtension into %T", into)
+	}
+	return nil
+}
+
 // Object2Map turn the Object to a map
 func Object2Map(obj interface{}) (map[string]interface{}, error) {
 	var res map[string]interface{}
@@ -645,7 +1932,8 @@ func Object2Map(obj interface{}) (map[string]interface{}, error) {
 	return res, err
 }
 
-// Object2RawExtension converts an object to a rawExtension
+// Object2RawExtension converts an object to a rawExtension. It panics if the object cannot be marshaled, use
+// Object2RawExtensionSafe if the object is not guaranteed to be valid.
 func Object2RawExtension(obj interface{}) *runtime.RawExtension {
 	bts := MustJSONMarshal(obj)
 	return &runtime.RawExtension{
@@ -653,6 +1941,18 @@ func Object2RawExtension(obj interface{}) *runtime.RawExtension {
 	}
 }
 
+// Object2RawExtensionSafe converts an object to a rawExtension like Object2RawExtension, but returns the marshal
+// error instead of panicking.
+func Object2RawExtensionSafe(obj interface{}) (*runtime.RawExtension, error) {
+	bts, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %T into rawExtension", obj)
+	}
+	return &runtime.RawExtension{
+		Raw: bts,
+	}, nil
+}
+
 // MustJSONMarshal json-marshals an object into bytes. It panics on err.
 func MustJSONMarshal(obj interface{}) []byte {
 	b, err := json.Marshal(obj)
@@ -679,44 +1979,453 @@ func RawExtension2Map(raw *runtime.RawExtension) (map[string]interface{}, error)
 	return ret, err
 }
 
-// GenTraitName generate trait name
-func GenTraitName(componentName string, ct *unstructured.Unstructured, traitType string) string {
-	var traitMiddleName = TraitPrefixKey
-	if traitType != "" && traitType != Dummy {
-		traitMiddleName = strings.ToLower(traitType)
+// yaml2Map converts the YAML or JSON document to a map, the integers are decoded as int64 to keep the fidelity of
+// large numbers and the others are decoded as float64
+func yaml2Map(data []byte) (map[string]interface{}, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var res map[string]interface{}
+	if err := utiljson.Unmarshal(jsonData, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Object2MapYAML turn the Object to a map through YAML, unlike Object2Map, the large integers will not be rounded
+func Object2MapYAML(obj interface{}) (map[string]interface{}, error) {
+	bts, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return yaml2Map(bts)
+}
+
+// Object2UnstructuredYAML converts an object to an unstructured struct through YAML
+func Object2UnstructuredYAML(obj interface{}) (*unstructured.Unstructured, error) {
+	objMap, err := Object2MapYAML(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{
+		Object: objMap,
+	}, nil
+}
+
+// RawExtension2MapYAML will convert rawExtension in YAML or JSON to map, the large integers will not be rounded
+func RawExtension2MapYAML(raw *runtime.RawExtension) (map[string]interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := raw.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return yaml2Map(data)
+}
+
+// canonicalListFields are the well-known list fields whose order is not meaningful, the items are sorted by the
+// values of the key fields in order when canonicalizing
+var canonicalListFields = map[string][]string{
+	"env":          {"name"},
+	"envFrom":      {"prefix", "configMapRef", "secretRef"},
+	"ports":        {"name", "containerPort", "port", "protocol"},
+	"volumes":      {"name"},
+	"volumeMounts": {"mountPath", "name"},
+	"tolerations":  {"key", "operator", "value", "effect"},
+}
+
+// CanonicalizeTraitForHash returns a copy of the trait whose well-known unordered list fields like env and ports
+// are sorted, so that the semantically equal traits get the same hash. It can be used to generate deterministic
+// trait names, e.g. GenTraitName(componentName, CanonicalizeTraitForHash(trait), traitType).
+func CanonicalizeTraitForHash(trait *unstructured.Unstructured) *unstructured.Unstructured {
+	if trait == nil {
+		return nil
+	}
+	canonical := trait.DeepCopy()
+	canonicalizeValue(canonical.Object)
+	return canonical
+}
+
+func canonicalizeValue(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			canonicalizeValue(field)
+			items, isList := field.([]interface{})
+			keyFields, known := canonicalListFields[key]
+			if !isList || !known {
+				continue
+			}
+			sortKey := func(item interface{}) string {
+				m, ok := item.(map[string]interface{})
+				if !ok {
+					return fmt.Sprint(item)
+				}
+				var values []string
+				for _, keyField := range keyFields {
+					values = append(values, fmt.Sprint(m[keyField]))
+				}
+				return strings.Join(values, "\x00")
+			}
+			sort.SliceStable(items, func(i, j int) bool {
+				return sortKey(items[i]) < sortKey(items[j])
+			})
+		}
+	case []interface{}:
+		for _, item := range v {
+			canonicalizeValue(item)
+		}
+	}
+}
+
+// GenTraitName generate trait name
+func GenTraitName(componentName string, ct *unstructured.Unstructured, traitType string) string {
+	return GenTraitNameWithCollisionCount(componentName, ct, traitType, nil)
+}
+
+// GenTraitNameWithCollisionCount generate trait name with a collisionCount to avoid name collision
+// between different traits which have the same hash value
+func GenTraitNameWithCollisionCount(componentName string, ct *unstructured.Unstructured, traitType string, collisionCount *int32) string {
+	return genTraitName(componentName, traitType, ComputeHashWithCollisionCount(ct, collisionCount))
+}
+
+// GenTraitNameWithHashFunc generate trait name with the given hash function, e.g. ComputeHashSHA256
+func GenTraitNameWithHashFunc(componentName string, ct *unstructured.Unstructured, traitType string, hashFunc func(*unstructured.Unstructured) string) string {
+	return genTraitName(componentName, traitType, hashFunc(ct))
+}
+
+func genTraitName(componentName, traitType, hash string) string {
+	var traitMiddleName = TraitPrefixKey
+	if !IsDummyTraitType(traitType) {
+		traitMiddleName = strings.ToLower(traitType)
+	}
+	return BuildStableName(hash, componentName, traitMiddleName)
+}
+
+// ParseTraitName reverses the `component-traittype-hash` format of GenTraitName. Since both the component name and
+// the trait type may contain `-`, the known component name is used as the hint to split the name. If the hint is
+// empty, the name is parsed only when it's unambiguous, i.e. neither the component name nor the trait type contains
+// `-`. The trait type of dummy traits is parsed as TraitPrefixKey, and the names truncated by the length limit cannot
+// be parsed unless the component name is kept as it is.
+func ParseTraitName(name, componentHint string) (componentName, traitType, hash string, ok bool) {
+	sep := strings.LastIndex(name, "-")
+	if sep <= 0 || sep == len(name)-1 {
+		return "", "", "", false
+	}
+	rest, hash := name[:sep], name[sep+1:]
+	if componentHint != "" {
+		middle, found := strings.CutPrefix(rest, componentHint+"-")
+		if !found || middle == "" {
+			return "", "", "", false
+		}
+		return componentHint, middle, hash, true
+	}
+	parts := strings.Split(rest, "-")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], hash, true
+}
+
+// BuildStableName joins the parts and the hash with `-` into a name which fits the 253-char limit of the
+// kubernetes object names. If the name is too long, the hash is kept as it is and the parts are truncated
+// deterministically: the length budget is shared fairly by the parts, the parts shorter than their share are
+// kept and the rest of the budget is given to the longer ones, each of which is cut to its budget and trimmed
+// of the trailing `-` and `.`. The truncation only depends on the lengths of the parts, so the name is stable.
+func BuildStableName(hash string, parts ...string) string {
+	name := strings.Join(append(append([]string{}, parts...), hash), "-")
+	if len(name) <= validation.DNS1123SubdomainMaxLength {
+		return name
+	}
+	budget := validation.DNS1123SubdomainMaxLength - len(hash) - len(parts)
+	order := make([]int, len(parts))
+	for i := range order {
+		order[i] = i
 	}
-	return fmt.Sprintf("%s-%s-%s", componentName, traitMiddleName, ComputeHash(ct))
+	sort.SliceStable(order, func(i, j int) bool { return len(parts[order[i]]) < len(parts[order[j]]) })
+	allowed := make([]int, len(parts))
+	for i, idx := range order {
+		share := budget / (len(parts) - i)
+		allowed[idx] = min(len(parts[idx]), share)
+		budget -= allowed[idx]
+	}
+	truncated := make([]string, 0, len(parts)+1)
+	for i, part := range parts {
+		if part = strings.TrimRight(part[:allowed[i]], "-."); part != "" {
+			truncated = append(truncated, part)
+		}
+	}
+	return strings.Join(append(truncated, hash), "-")
+}
+
+// ComputeHash returns a hash value calculated from the trait. The hash will be
+// safe encoded to avoid bad words.
+func ComputeHash(trait *unstructured.Unstructured) string {
+	return HashObject(*trait)
 }
 
-// ComputeHash returns a hash value calculated from pod template and
+// ComputeHashWithCollisionCount returns a hash value calculated from the trait and
 // a collisionCount to avoid hash collision. The hash will be safe encoded to
 // avoid bad words.
-func ComputeHash(trait *unstructured.Unstructured) string {
+func ComputeHashWithCollisionCount(trait *unstructured.Unstructured, collisionCount *int32) string {
 	componentTraitHasher := fnv.New32a()
 	DeepHashObject(componentTraitHasher, *trait)
 
+	// Add collisionCount in the hash if it exists, the same as the hash of Deployment
+	if collisionCount != nil {
+		collisionCountBytes := make([]byte, 8)
+		binary.LittleEndian.PutUint32(collisionCountBytes, uint32(*collisionCount))
+		_, _ = componentTraitHasher.Write(collisionCountBytes)
+	}
+
 	return rand.SafeEncodeString(fmt.Sprint(componentTraitHasher.Sum32()))
 }
 
+// ComputeHashSHA256 returns a hash value calculated from the trait by sha256, which has a lower
+// collision probability than ComputeHash. The hash is truncated to 64 bits and safe encoded to avoid bad words.
+func ComputeHashSHA256(trait *unstructured.Unstructured) string {
+	componentTraitHasher := sha256.New()
+	DeepHashObject(componentTraitHasher, *trait)
+
+	return rand.SafeEncodeString(fmt.Sprint(binary.BigEndian.Uint64(componentTraitHasher.Sum(nil))))
+}
+
+// CanonicalJSON marshals the object into the canonical JSON, whose object keys are sorted and which contains no
+// insignificant whitespace and no HTML escaping, so the output only depends on the content of the object.
+func CanonicalJSON(obj interface{}) ([]byte, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	// decode into the generic form to sort the keys of the structs as well as the maps
+	var generic interface{}
+	if err = utiljson.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// ComputeHashStable returns a hash value calculated from the canonical JSON of the trait like ComputeHash, but
+// it doesn't depend on the output format of spew, so the hash is stable across the upgrades of the dependencies.
+func ComputeHashStable(trait *unstructured.Unstructured) (string, error) {
+	data, err := CanonicalJSON(trait.Object)
+	if err != nil {
+		return "", err
+	}
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(data)
+	return rand.SafeEncodeString(fmt.Sprint(hasher.Sum32())), nil
+}
+
+// HashObject returns the safe encoded fnv hash of the object written by DeepHashObject. Use ComputeHashMulti to
+// combine multiple objects into one hash.
+func HashObject(obj interface{}) string {
+	hasher := fnv.New32a()
+	DeepHashObject(hasher, obj)
+	return rand.SafeEncodeString(fmt.Sprint(hasher.Sum32()))
+}
+
+// ComputeHashMulti returns the safe encoded fnv hash of the objects written into one hasher in order, e.g. a
+// workload and its traits, so the hash changes if any object changes or the order changes. The hash of a single
+// object is the same as HashObject.
+func ComputeHashMulti(objs ...interface{}) string {
+	hasher := fnv.New32a()
+	for _, obj := range objs {
+		_, _ = hashPrinter.Fprintf(hasher, "%#v", obj)
+	}
+	return rand.SafeEncodeString(fmt.Sprint(hasher.Sum32()))
+}
+
+// ComputeSpecHash computes the hash value of a k8s resource spec
+func ComputeSpecHash(spec interface{}) (string, error) {
+	// compute a hash value of any resource spec
+	specHash, err := hashstructure.Hash(spec, hashstructure.FormatV2, nil)
+	if err != nil {
+		return "", err
+	}
+	specHashLabel := strconv.FormatUint(specHash, 16)
+	return specHashLabel, nil
+}
+
 // DeepHashObject writes specified object to hash using the spew library
 // which follows pointers and prints actual values of the nested objects
 // ensuring the hash does not change when a pointer changes.
 func DeepHashObject(hasher hash.Hash, objectToWrite interface{}) {
 	hasher.Reset()
-	printer := spew.ConfigState{
-		Indent:         " ",
-		SortKeys:       true,
-		DisableMethods: true,
-		SpewKeys:       true,
+	_, _ = hashPrinter.Fprintf(hasher, "%#v", objectToWrite)
+}
+
+// hashPrinter is the spew config used by DeepHashObject, it is only read by spew, so it's safe to be shared
+// by the concurrent calls and saves the allocation of the config on every call
+var hashPrinter = &spew.ConfigState{
+	Indent:         " ",
+	SortKeys:       true,
+	DisableMethods: true,
+	SpewKeys:       true,
+}
+
+// DeepHashObjectExcluding writes specified object to hash like DeepHashObject, but the fields in the given
+// dotted paths, e.g. `metadata.resourceVersion`, are pruned from the map representation of the object before hashing.
+// If the object cannot be converted to a map, it will be hashed as a whole.
+func DeepHashObjectExcluding(hasher hash.Hash, objectToWrite interface{}, paths []string) {
+	var objMap map[string]interface{}
+	switch obj := objectToWrite.(type) {
+	case unstructured.Unstructured:
+		objMap = obj.DeepCopy().Object
+	case *unstructured.Unstructured:
+		objMap = obj.DeepCopy().Object
+	default:
+		var err error
+		if objMap, err = Object2Map(objectToWrite); err != nil {
+			DeepHashObject(hasher, objectToWrite)
+			return
+		}
+	}
+	for _, path := range paths {
+		unstructured.RemoveNestedField(objMap, strings.Split(path, ".")...)
 	}
-	_, _ = printer.Fprintf(hasher, "%#v", objectToWrite)
+	DeepHashObject(hasher, objMap)
+}
+
+// DefaultDriftIgnorePaths are the dotted paths managed by the server, which should be ignored by ObjectsDiffer
+var DefaultDriftIgnorePaths = []string{
+	"metadata.resourceVersion",
+	"metadata.uid",
+	"metadata.generation",
+	"metadata.creationTimestamp",
+	"metadata.managedFields",
+	"status",
+}
+
+// ObjectsDiffer checks if the live object drifts from the desired one. The objects are compared in the map form
+// converted by Object2Map, so the numbers of different go types are compared by value, and the fields in the
+// dotted ignore paths, e.g. DefaultDriftIgnorePaths, are pruned before comparing. It returns the sorted top-level
+// paths which differ.
+func ObjectsDiffer(desired, live *unstructured.Unstructured, ignorePaths []string) (bool, []string, error) {
+	desiredMap, err := Object2Map(desired.Object)
+	if err != nil {
+		return false, nil, errors.Wrap(err, "cannot convert the desired object")
+	}
+	liveMap, err := Object2Map(live.Object)
+	if err != nil {
+		return false, nil, errors.Wrap(err, "cannot convert the live object")
+	}
+	for _, path := range ignorePaths {
+		fields := strings.Split(path, ".")
+		unstructured.RemoveNestedField(desiredMap, fields...)
+		unstructured.RemoveNestedField(liveMap, fields...)
+	}
+	var diffs []string
+	for key, value := range desiredMap {
+		if liveValue, ok := liveMap[key]; !ok || !reflect.DeepEqual(value, liveValue) {
+			diffs = append(diffs, key)
+		}
+	}
+	for key := range liveMap {
+		if _, ok := desiredMap[key]; !ok {
+			diffs = append(diffs, key)
+		}
+	}
+	sort.Strings(diffs)
+	return len(diffs) > 0, diffs, nil
 }
 
 // AddLabels will merge labels with existing labels. If any conflict keys, use new value to override existing value.
+// Controllers should prefer AddLabelsProtected when the labels are supplied by users.
 func AddLabels(o labelAnnotationObject, labels map[string]string) {
 	o.SetLabels(MergeMapOverrideWithDst(o.GetLabels(), labels))
 }
 
+// LabelChange is the change of a label key which will be made by LabelsPlan
+type LabelChange struct {
+	Key     string
+	Existed bool
+	Before  string
+	After   string
+}
+
+// LabelsPlanItem records the label changes of an object
+type LabelsPlanItem struct {
+	Object  metav1.Object
+	Changes []LabelChange
+}
+
+// LabelsPlan is the plan of adding labels to a set of objects, which can be previewed before applied
+type LabelsPlan struct {
+	Items  []LabelsPlanItem
+	labels map[string]string
+}
+
+// ApplyLabelsPlan computes which labels would be changed by AddLabels on each object without mutating them,
+// the changes of each object are sorted by key. Call Apply on the plan to commit the changes.
+func ApplyLabelsPlan(objs []metav1.Object, labels map[string]string) *LabelsPlan {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	plan := &LabelsPlan{labels: labels}
+	for _, obj := range objs {
+		item := LabelsPlanItem{Object: obj}
+		existing := obj.GetLabels()
+		for _, k := range keys {
+			before, existed := existing[k]
+			if existed && before == labels[k] {
+				continue
+			}
+			item.Changes = append(item.Changes, LabelChange{Key: k, Existed: existed, Before: before, After: labels[k]})
+		}
+		plan.Items = append(plan.Items, item)
+	}
+	return plan
+}
+
+// HasChanges checks if any object will be changed by the plan
+func (p *LabelsPlan) HasChanges() bool {
+	for _, item := range p.Items {
+		if len(item.Changes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply adds the labels to the objects which will be changed by the plan
+func (p *LabelsPlan) Apply() {
+	for _, item := range p.Items {
+		if len(item.Changes) > 0 {
+			AddLabels(item.Object, p.labels)
+		}
+	}
+}
+
+// AddLabelsProtected will merge labels with existing labels like AddLabels, but the existing labels whose keys are
+// in the reserved set will not be overridden, e.g. app.oam.dev/component which controllers rely on for ownership.
+// It returns the sorted keys which are skipped.
+func AddLabelsProtected(o labelAnnotationObject, labels map[string]string, reserved []string) []string {
+	existing := o.GetLabels()
+	var skipped []string
+	toAdd := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if old, ok := existing[k]; ok && old != v && slices.Contains(reserved, k) {
+			skipped = append(skipped, k)
+			continue
+		}
+		toAdd[k] = v
+	}
+	AddLabels(o, toAdd)
+	sort.Strings(skipped)
+	return skipped
+}
+
 // AddAnnotations will merge annotations with existing ones. If any conflict keys, use new value to override existing value.
 func AddAnnotations(o labelAnnotationObject, annos map[string]string) {
 	o.SetAnnotations(MergeMapOverrideWithDst(o.GetAnnotations(), annos))
@@ -738,50 +2447,310 @@ func MergeMapOverrideWithDst(src, dst map[string]string) map[string]string {
 	return r
 }
 
-// ExtractComponentName will extract the componentName from a revisionName
+// MergeAnnotationsBounded merges the annotations like MergeMapOverrideWithDst, but returns an error if the total size
+// of the merged annotations exceeds the limit of kubernetes, so that the oversized annotations can be skipped instead
+// of failing the apply. The error names the largest annotations propagated from src which should be dropped to fit.
+func MergeAnnotationsBounded(src, dst map[string]string) (map[string]string, error) {
+	merged := MergeMapOverrideWithDst(src, dst)
+	total := 0
+	for k, v := range merged {
+		total += len(k) + len(v)
+	}
+	if total <= apivalidation.TotalAnnotationSizeLimitB {
+		return merged, nil
+	}
+	var propagated []string
+	for k := range src {
+		if _, ok := dst[k]; !ok {
+			propagated = append(propagated, k)
+		}
+	}
+	sort.Slice(propagated, func(i, j int) bool {
+		si, sj := len(propagated[i])+len(src[propagated[i]]), len(propagated[j])+len(src[propagated[j]])
+		if si != sj {
+			return si > sj
+		}
+		return propagated[i] < propagated[j]
+	})
+	var oversized []string
+	for _, k := range propagated {
+		if total <= apivalidation.TotalAnnotationSizeLimitB {
+			break
+		}
+		oversized = append(oversized, k)
+		total -= len(k) + len(src[k])
+	}
+	return nil, errors.Errorf("the total size of the merged annotations exceeds the limit %d bytes, the oversized annotations: [%s]",
+		apivalidation.TotalAnnotationSizeLimitB, strings.Join(oversized, ","))
+}
+
+// MergeMapOverrideWithSrc merges two could be nil maps. Keep the src for any conflicts,
+// it is the inverse precedence of MergeMapOverrideWithDst.
+func MergeMapOverrideWithSrc(src, dst map[string]string) map[string]string {
+	return MergeMapOverrideWithDst(dst, src)
+}
+
+// DefaultMergeDeleteSentinel is the default value in dst which tells MergeMapWithDeletes to delete the key
+const DefaultMergeDeleteSentinel = "-"
+
+// MergeMapWithDeletes merges two could be nil maps like MergeMapOverrideWithDst, but the keys whose value in
+// dst equals to the sentinel will be deleted from the result, which provides the patch semantics for the labels
+// and annotations. DefaultMergeDeleteSentinel will be used if the sentinel is empty.
+func MergeMapWithDeletes(src, dst map[string]string, sentinel string) map[string]string {
+	if sentinel == "" {
+		sentinel = DefaultMergeDeleteSentinel
+	}
+	r := MergeMapOverrideWithDst(src, dst)
+	for k, v := range dst {
+		if v == sentinel {
+			delete(r, k)
+		}
+	}
+	return r
+}
+
+// revisionNumRegexp matches the revision number token like `v1`
+var revisionNumRegexp = regexp.MustCompile(`^v\d+$`)
+
+// revisionSuffixRegexp matches the trailing segment of a revision name, it can be a revision number like `v1`
+// or a hash generated for ControllerRevision which is safe encoded by rand.SafeEncodeString
+var revisionSuffixRegexp = regexp.MustCompile(`^(v\d+|[bcdfghjklmnpqrstvwxz2456789]{5,10})$`)
+
+// MergeMapDeepOption is the option for MergeMapDeep
+type MergeMapDeepOption func(*mergeMapDeepConfig)
+
+type mergeMapDeepConfig struct {
+	appendSlice bool
+}
+
+// MergeMapDeepWithAppendSlice makes MergeMapDeep append the dst slice to the src one instead of replacing it
+func MergeMapDeepWithAppendSlice() MergeMapDeepOption {
+	return func(cfg *mergeMapDeepConfig) {
+		cfg.appendSlice = true
+	}
+}
+
+// MergeMapDeep merges two could be nil maps recursively. Nested maps will be merged, keep the dst for any
+// other conflicts, which means slices in dst will replace the ones in src by default.
+func MergeMapDeep(src, dst map[string]interface{}, opts ...MergeMapDeepOption) map[string]interface{} {
+	if src == nil && dst == nil {
+		return nil
+	}
+	cfg := &mergeMapDeepConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return mergeMapDeep(src, dst, cfg)
+}
+
+func mergeMapDeep(src, dst map[string]interface{}, cfg *mergeMapDeepConfig) map[string]interface{} {
+	r := make(map[string]interface{}, len(src)+len(dst))
+	for k, v := range src {
+		r[k] = v
+	}
+	for k, v := range dst {
+		switch dstValue := v.(type) {
+		case map[string]interface{}:
+			if srcValue, ok := r[k].(map[string]interface{}); ok {
+				r[k] = mergeMapDeep(srcValue, dstValue, cfg)
+				continue
+			}
+		case []interface{}:
+			if srcValue, ok := r[k].([]interface{}); ok && cfg.appendSlice {
+				merged := make([]interface{}, 0, len(srcValue)+len(dstValue))
+				r[k] = append(append(merged, srcValue...), dstValue...)
+				continue
+			}
+		}
+		r[k] = v
+	}
+	return r
+}
+
+// ExtractComponentName will extract the componentName from a revisionName,
+// the revisionName will be returned directly if it doesn't end with a revision
 func ExtractComponentName(revisionName string) string {
-	splits := strings.Split(revisionName, "-")
-	return strings.Join(splits[0:len(splits)-1], "-")
+	componentName, err := ExtractComponentNameWithDelimiter(revisionName, "-")
+	if err != nil {
+		return revisionName
+	}
+	return componentName
+}
+
+// ExtractComponentNameWithDelimiter will extract the componentName from a revisionName joined by the delimiter,
+// it returns an error if the trailing segment is not a revision, e.g. `my-app-2`
+func ExtractComponentNameWithDelimiter(revisionName, delimiter string) (string, error) {
+	idx := strings.LastIndex(revisionName, delimiter)
+	if len(delimiter) == 0 || idx <= 0 || !revisionSuffixRegexp.MatchString(revisionName[idx+len(delimiter):]) {
+		return "", errors.New(ErrBadRevision)
+	}
+	return revisionName[:idx], nil
 }
 
 // ExtractRevisionNum  extract revision number
 func ExtractRevisionNum(appRevision string, delimiter string) (int, error) {
 	splits := strings.Split(appRevision, delimiter)
-	// check some bad appRevision name, eg:v1, appv2
-	if len(splits) == 1 {
+	// check some bad appRevision name, eg:v1, appv2, myapp-a1, myapp-v1-vv
+	num, index, found := lastRevisionNumToken(splits)
+	if !found || index != len(splits)-1 {
 		return 0, errors.New(ErrBadRevision)
 	}
-	// check some bad appRevision name, eg:myapp-a1
-	if !strings.HasPrefix(splits[len(splits)-1], "v") {
-		return 0, errors.New(ErrBadRevision)
+	return num, nil
+}
+
+// CompareRevisionNames compares the revision numbers of the two revision names extracted by ExtractRevisionNum,
+// it returns -1 if a is older than b, 1 if a is newer than b and 0 if they have the same revision number
+func CompareRevisionNames(a, b string, delimiter string) (int, error) {
+	numA, err := ExtractRevisionNum(a, delimiter)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot extract revision number from %s", a)
+	}
+	numB, err := ExtractRevisionNum(b, delimiter)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot extract revision number from %s", b)
 	}
-	return strconv.Atoi(strings.TrimPrefix(splits[len(splits)-1], "v"))
+	return cmp.Compare(numA, numB), nil
 }
 
-// Min for int
-func Min(a, b int) int {
+// DefaultRevisionDelimiters is the delimiters used by ExtractRevisionNumFlexible if no delimiter is specified
+var DefaultRevisionDelimiters = []string{"-", ".", "@"}
+
+// ExtractRevisionNumFlexible extract revision number from the last `v<number>` token of the revision name,
+// the tokens are split by any of the delimiters, and the trailing suffix after the token is ignored,
+// e.g. myapp-v3-suffix and myapp.v3 are both revision 3
+func ExtractRevisionNumFlexible(revisionName string, delimiters ...string) (int, error) {
+	if len(delimiters) == 0 {
+		delimiters = DefaultRevisionDelimiters
+	}
+	num, _, found := lastRevisionNumToken(splitByDelimiters(revisionName, delimiters))
+	if !found {
+		return 0, errors.Errorf("%s: no revision number like v1 is found in %q", ErrBadRevision, revisionName)
+	}
+	return num, nil
+}
+
+// splitByDelimiters splits the string by any of the delimiters
+func splitByDelimiters(s string, delimiters []string) []string {
+	tokens := []string{s}
+	for _, delimiter := range delimiters {
+		if delimiter == "" {
+			continue
+		}
+		var next []string
+		for _, token := range tokens {
+			next = append(next, strings.Split(token, delimiter)...)
+		}
+		tokens = next
+	}
+	return tokens
+}
+
+// lastRevisionNumToken finds the last `v<number>` token which is not the first token, the first token
+// is regarded as the name
+func lastRevisionNumToken(tokens []string) (num int, index int, found bool) {
+	for i := len(tokens) - 1; i > 0; i-- {
+		if !revisionNumRegexp.MatchString(tokens[i]) {
+			continue
+		}
+		num, err := strconv.Atoi(strings.TrimPrefix(tokens[i], "v"))
+		if err != nil {
+			continue
+		}
+		return num, i, true
+	}
+	return 0, 0, false
+}
+
+// SignedNumber is the constraint of the signed integer and float types
+type SignedNumber interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~float32 | ~float64
+}
+
+// MinOf returns the smaller one of a and b
+func MinOf[T cmp.Ordered](a, b T) T {
 	if a < b {
 		return a
 	}
 	return b
 }
 
-// Max for int
-func Max(a, b int) int {
+// MaxOf returns the larger one of a and b
+func MaxOf[T cmp.Ordered](a, b T) T {
 	if a > b {
 		return a
 	}
 	return b
 }
 
-// Abs for int
-func Abs(a int) int {
+// AbsOf returns the absolute value of a
+func AbsOf[T SignedNumber](a T) T {
 	if a < 0 {
 		return -a
 	}
 	return a
 }
 
+// ClampOf restricts value to the range [low, high], the bounds will be swapped if low is larger than high
+func ClampOf[T cmp.Ordered](value, low, high T) T {
+	if low > high {
+		low, high = high, low
+	}
+	return MaxOf(MinOf(value, high), low)
+}
+
+// Min for int
+func Min(a, b int) int {
+	return MinOf(a, b)
+}
+
+// Max for int
+func Max(a, b int) int {
+	return MaxOf(a, b)
+}
+
+// Abs for int
+func Abs(a int) int {
+	return AbsOf(a)
+}
+
+// Clamp for int
+func Clamp(value, low, high int) int {
+	return ClampOf(value, low, high)
+}
+
+// MinQuantity returns a copy of the smaller one of the quantities
+func MinQuantity(a, b resource.Quantity) resource.Quantity {
+	if a.Cmp(b) <= 0 {
+		return a.DeepCopy()
+	}
+	return b.DeepCopy()
+}
+
+// MaxQuantity returns a copy of the larger one of the quantities
+func MaxQuantity(a, b resource.Quantity) resource.Quantity {
+	if a.Cmp(b) >= 0 {
+		return a.DeepCopy()
+	}
+	return b.DeepCopy()
+}
+
+// AbsQuantity returns a copy of the absolute value of the quantity
+func AbsQuantity(q resource.Quantity) resource.Quantity {
+	abs := q.DeepCopy()
+	if abs.Sign() < 0 {
+		abs.Neg()
+	}
+	return abs
+}
+
+// ClampQuantity restricts the quantity to the range [low, high] like ClampOf, and returns a copy of the result
+func ClampQuantity(value, low, high resource.Quantity) resource.Quantity {
+	if low.Cmp(high) > 0 {
+		low, high = high, low
+	}
+	return MaxQuantity(MinQuantity(value, high), low)
+}
+
 // AsOwner converts the supplied object reference to an owner reference.
 func AsOwner(r *corev1.ObjectReference) metav1.OwnerReference {
 	return metav1.OwnerReference{
@@ -801,6 +2770,66 @@ func AsController(r *corev1.ObjectReference) metav1.OwnerReference {
 	return ref
 }
 
+// AsOwnerFromObject converts the supplied object to an owner reference, the GVK of the object must be populated.
+func AsOwnerFromObject(obj client.Object) (metav1.OwnerReference, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return metav1.OwnerReference{}, errors.Errorf("the GroupVersionKind of object %s is not populated", client.ObjectKeyFromObject(obj))
+	}
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	return AsOwner(&corev1.ObjectReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+	}), nil
+}
+
+// FindControllerRef returns the controller reference of the object, or nil if the object is not controlled.
+// It has the same semantics as metav1.GetControllerOf.
+func FindControllerRef(child metav1.Object) *metav1.OwnerReference {
+	return metav1.GetControllerOf(child)
+}
+
+// IsControlledBy checks whether the object is controlled by the owner with the given UID
+func IsControlledBy(child metav1.Object, ownerUID types.UID) bool {
+	ref := FindControllerRef(child)
+	return ref != nil && ref.UID == ownerUID
+}
+
+// OwnerReferenceOption is the option for AsOwnerWithOptions
+type OwnerReferenceOption func(*metav1.OwnerReference)
+
+// WithController sets the Controller field of the owner reference
+func WithController(controller bool) OwnerReferenceOption {
+	return func(ref *metav1.OwnerReference) {
+		ref.Controller = &controller
+	}
+}
+
+// WithBlockOwnerDeletion sets the BlockOwnerDeletion field of the owner reference, the foreground deletion
+// of the owner will wait for the child to be deleted if it is true
+func WithBlockOwnerDeletion(block bool) OwnerReferenceOption {
+	return func(ref *metav1.OwnerReference) {
+		ref.BlockOwnerDeletion = &block
+	}
+}
+
+// AsOwnerWithOptions converts the supplied object reference to an owner reference with the options.
+func AsOwnerWithOptions(r *corev1.ObjectReference, opts ...OwnerReferenceOption) metav1.OwnerReference {
+	ref := AsOwner(r)
+	for _, opt := range opts {
+		opt(&ref)
+	}
+	return ref
+}
+
+// AsControllerWithBlockOwnerDeletion converts the supplied object reference to a controller reference
+// which blocks the foreground deletion of the owner until the child is deleted.
+func AsControllerWithBlockOwnerDeletion(r *corev1.ObjectReference) metav1.OwnerReference {
+	return AsOwnerWithOptions(r, WithController(true), WithBlockOwnerDeletion(true))
+}
+
 // NamespaceAccessor namespace accessor for resource
 type NamespaceAccessor interface {
 	For(obj client.Object) string
@@ -835,3 +2864,137 @@ func (accessor *applicationResourceNamespaceAccessor) Namespace() string {
 func NewApplicationResourceNamespaceAccessor(appNs, overrideNs string) NamespaceAccessor {
 	return &applicationResourceNamespaceAccessor{applicationNamespace: appNs, overrideNamespace: overrideNs}
 }
+
+type contextAwareNamespaceAccessor struct {
+	ctx                  context.Context
+	applicationNamespace string
+}
+
+// For access namespace for resource
+func (accessor *contextAwareNamespaceAccessor) For(obj client.Object) string {
+	return NewApplicationResourceNamespaceAccessor(accessor.applicationNamespace, GetOverrideNamespaceWithCtx(accessor.ctx)).For(obj)
+}
+
+// Namespace the namespace by default
+func (accessor *contextAwareNamespaceAccessor) Namespace() string {
+	return NewApplicationResourceNamespaceAccessor(accessor.applicationNamespace, GetOverrideNamespaceWithCtx(accessor.ctx)).Namespace()
+}
+
+// NewContextAwareNamespaceAccessor create namespace accessor for resource in application like
+// NewApplicationResourceNamespaceAccessor, but the override namespace is read from the context set by
+// SetOverrideNamespaceInCtx, which is consistent with the namespaces of definitions in context.
+func NewContextAwareNamespaceAccessor(ctx context.Context, appNs string) NamespaceAccessor {
+	return &contextAwareNamespaceAccessor{ctx: ctx, applicationNamespace: appNs}
+}
+
+type labelNamespaceAccessor struct {
+	applicationNamespace string
+	labelKey             string
+	template             string
+}
+
+// For access namespace for resource by the label value, e.g. the resource labeled with `tenant: foo` will be
+// put into namespace `tenant-foo` with the template `tenant-%s`. The application namespace is used if the label
+// is absent or empty.
+func (accessor *labelNamespaceAccessor) For(obj client.Object) string {
+	value := obj.GetLabels()[accessor.labelKey]
+	if value == "" {
+		return accessor.applicationNamespace
+	}
+	return strings.ReplaceAll(accessor.template, "%s", value)
+}
+
+// Namespace the namespace by default
+func (accessor *labelNamespaceAccessor) Namespace() string {
+	return accessor.applicationNamespace
+}
+
+// NewLabelNamespaceAccessor create namespace accessor which resolves the namespace of resource by its label,
+// the `%s` in template will be substituted with the label value, the label value is used directly if template is empty
+func NewLabelNamespaceAccessor(appNs, labelKey, template string) NamespaceAccessor {
+	if template == "" {
+		template = "%s"
+	}
+	return &labelNamespaceAccessor{applicationNamespace: appNs, labelKey: labelKey, template: template}
+}
+
+// NewValidatedApplicationResourceNamespaceAccessor create namespace accessor for resource in application, the
+// application namespace and the override namespace (if set) are validated against the kubernetes namespace name rules
+func NewValidatedApplicationResourceNamespaceAccessor(appNs, overrideNs string) (NamespaceAccessor, error) {
+	if errs := validation.IsDNS1123Label(appNs); len(errs) != 0 {
+		return nil, errors.Errorf("invalid application namespace %q:%s", appNs, strings.Join(errs, ","))
+	}
+	if overrideNs != "" {
+		if errs := validation.IsDNS1123Label(overrideNs); len(errs) != 0 {
+			return nil, errors.Errorf("invalid override namespace %q:%s", overrideNs, strings.Join(errs, ","))
+		}
+	}
+	return NewApplicationResourceNamespaceAccessor(appNs, overrideNs), nil
+}
+
+type gvkNamespaceAccessor struct {
+	NamespaceAccessor
+	namespaces map[string]string
+}
+
+// For access namespace for resource, the namespace mapped by the GroupKind of the resource takes precedence over the
+// one mapped by the Kind, and the resource without mapping falls back to the application resource namespace
+func (accessor *gvkNamespaceAccessor) For(obj client.Object) string {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Kind != "" {
+		if ns, ok := accessor.namespaces[gvk.GroupKind().String()]; ok {
+			return ns
+		}
+		if ns, ok := accessor.namespaces[gvk.Kind]; ok {
+			return ns
+		}
+	}
+	return accessor.NamespaceAccessor.For(obj)
+}
+
+// NewGVKNamespaceAccessor create namespace accessor for resource in application with per kind namespaces.
+// The keys of namespaces can be either a GroupKind like "Deployment.apps" or a Kind like "ConfigMap".
+func NewGVKNamespaceAccessor(appNs, overrideNs string, namespaces map[string]string) NamespaceAccessor {
+	return &gvkNamespaceAccessor{
+		NamespaceAccessor: NewApplicationResourceNamespaceAccessor(appNs, overrideNs),
+		namespaces:        namespaces,
+	}
+}
+
+// CheckedNamespaceAccessor namespace accessor for resource which is able to report the namespace that is not permitted
+type CheckedNamespaceAccessor interface {
+	NamespaceAccessor
+	ForChecked(obj client.Object) (string, error)
+}
+
+type allowlistNamespaceAccessor struct {
+	NamespaceAccessor
+	allowed []string
+}
+
+// ForChecked access namespace for resource by the base accessor, an error is returned if the namespace is not allowed
+func (accessor *allowlistNamespaceAccessor) ForChecked(obj client.Object) (string, error) {
+	ns := accessor.NamespaceAccessor.For(obj)
+	if !slices.Contains(accessor.allowed, ns) {
+		return "", errors.Errorf("namespace %q of %s %s is not in the allowed namespaces [%s]", ns,
+			obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), strings.Join(accessor.allowed, ","))
+	}
+	return ns, nil
+}
+
+// For access namespace for resource by the base accessor, the default namespace of the base accessor is returned if
+// the namespace is not allowed. Use ForChecked to reject the resource instead.
+func (accessor *allowlistNamespaceAccessor) For(obj client.Object) string {
+	ns, err := accessor.ForChecked(obj)
+	if err != nil {
+		klog.Warningf("%s, fall back to namespace %s", err.Error(), accessor.Namespace())
+		return accessor.Namespace()
+	}
+	return ns
+}
+
+// NewAllowlistNamespaceAccessor create namespace accessor which delegates to the base accessor, but only the
+// namespaces in the allowed list are permitted. The default namespace of the base accessor is expected to be allowed.
+func NewAllowlistNamespaceAccessor(base NamespaceAccessor, allowed []string) CheckedNamespaceAccessor {
+	return &allowlistNamespaceAccessor{NamespaceAccessor: base, allowed: allowed}
+}
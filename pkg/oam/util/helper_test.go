@@ -20,18 +20,41 @@ import (
 	"context"
 	"fmt"
 	"hash/adler32"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/davecgh/go-spew/spew"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/condition"
@@ -56,6 +79,20 @@ func TestUnstructured(t *testing.T) {
 			resource: "deployments",
 			exp:      "deployments.apps",
 		},
+		"core service": {
+			u: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+			}},
+			exp: "services",
+		},
+		"core configmap": {
+			u: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+			}},
+			exp: "configmaps",
+		},
 		"workload": {
 			u: &unstructured.Unstructured{Object: map[string]interface{}{
 				"apiVersion": "apps/v1",
@@ -157,6 +194,136 @@ func TestDeepHashObject(t *testing.T) {
 		assert.Equal(t, hash1, hash2)
 	}
 }
+func TestDeepHashObjectConcurrent(t *testing.T) {
+	trait := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Scaler",
+		"spec": map[string]interface{}{"replicas": int64(3), "labels": map[string]interface{}{"b": "2", "a": "1"}},
+	}}
+	// the shared config keeps the output of the config created per call
+	expected := fnv.New32a()
+	printer := spew.ConfigState{Indent: " ", SortKeys: true, DisableMethods: true, SpewKeys: true}
+	_, _ = printer.Fprintf(expected, "%#v", *trait)
+	hasher := fnv.New32a()
+	util.DeepHashObject(hasher, *trait)
+	assert.Equal(t, expected.Sum32(), hasher.Sum32())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h := fnv.New32a()
+			util.DeepHashObject(h, *trait)
+			assert.Equal(t, expected.Sum32(), h.Sum32())
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkDeepHashObject(b *testing.B) {
+	trait := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Scaler",
+		"spec": map[string]interface{}{"replicas": int64(3)},
+	}}
+	b.Run("shared config", func(b *testing.B) {
+		b.ReportAllocs()
+		hasher := fnv.New32a()
+		for i := 0; i < b.N; i++ {
+			util.DeepHashObject(hasher, trait)
+		}
+	})
+	b.Run("config per call", func(b *testing.B) {
+		b.ReportAllocs()
+		hasher := fnv.New32a()
+		for i := 0; i < b.N; i++ {
+			hasher.Reset()
+			printer := spew.ConfigState{Indent: " ", SortKeys: true, DisableMethods: true, SpewKeys: true}
+			_, _ = printer.Fprintf(hasher, "%#v", trait)
+		}
+	})
+}
+
+func TestDeepHashObjectExcluding(t *testing.T) {
+	paths := []string{"metadata.resourceVersion", "metadata.managedFields", "metadata.creationTimestamp", "status"}
+	newObj := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":            "test",
+				"resourceVersion": "1",
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(1),
+			},
+		}}
+	}
+	hashOf := func(obj interface{}) uint32 {
+		hasher := fnv.New32a()
+		util.DeepHashObjectExcluding(hasher, obj, paths)
+		return hasher.Sum32()
+	}
+	origin := hashOf(newObj())
+
+	obj := newObj()
+	obj.SetResourceVersion("2")
+	obj.SetCreationTimestamp(metav1.Now())
+	obj.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+	obj.Object["status"] = map[string]interface{}{"readyReplicas": int64(1)}
+	assert.Equal(t, origin, hashOf(obj))
+	assert.Equal(t, origin, hashOf(*obj))
+
+	obj.Object["spec"] = map[string]interface{}{"replicas": int64(2)}
+	assert.NotEqual(t, origin, hashOf(obj))
+
+	// the original object is not modified
+	assert.Equal(t, "2", obj.GetResourceVersion())
+}
+
+func TestGenTraitNameWithCollisionCount(t *testing.T) {
+	trait := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "core.oam.dev/v1alpha2",
+		"kind":       "ManualScalerTrait",
+		"spec": map[string]interface{}{
+			"replicaCount": int64(3),
+		},
+	}}
+
+	// a nil collisionCount keeps the hash the same as ComputeHash
+	assert.Equal(t, util.ComputeHash(trait), util.ComputeHashWithCollisionCount(trait, nil))
+	assert.Equal(t, util.GenTraitName("comp", trait, "scaler"), util.GenTraitNameWithCollisionCount("comp", trait, "scaler", nil))
+
+	// the same trait always collides with itself, increasing the collisionCount must produce distinct names
+	names := map[string]struct{}{}
+	for i := int32(0); i < 5; i++ {
+		collisionCount := i
+		name := util.GenTraitNameWithCollisionCount("comp", trait, "scaler", &collisionCount)
+		assert.Equal(t, name, util.GenTraitNameWithCollisionCount("comp", trait, "scaler", &collisionCount))
+		names[name] = struct{}{}
+	}
+	names[util.GenTraitName("comp", trait, "scaler")] = struct{}{}
+	assert.Equal(t, 6, len(names))
+}
+
+func TestComputeHashSHA256(t *testing.T) {
+	trait := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "core.oam.dev/v1alpha2",
+		"kind":       "ManualScalerTrait",
+		"spec": map[string]interface{}{
+			"replicaCount": int64(3),
+		},
+	}}
+	hash := util.ComputeHashSHA256(trait)
+	assert.Equal(t, hash, util.ComputeHashSHA256(trait.DeepCopy()))
+	assert.NotEqual(t, util.ComputeHash(trait), hash)
+
+	name := util.GenTraitNameWithHashFunc("comp", trait, "scaler", util.ComputeHashSHA256)
+	assert.Equal(t, "comp-scaler-"+hash, name)
+	assert.Equal(t, util.GenTraitName("comp", trait, "scaler"), util.GenTraitNameWithHashFunc("comp", trait, "scaler", util.ComputeHash))
+
+	trait.Object["spec"] = map[string]interface{}{"replicaCount": int64(4)}
+	assert.NotEqual(t, hash, util.ComputeHashSHA256(trait))
+}
 
 func TestEndReconcileWithNegativeCondition(t *testing.T) {
 
@@ -423,6 +590,198 @@ func TestMergeMapOverrideWithDst(t *testing.T) {
 
 }
 
+func TestMergeMapOverrideWithSrc(t *testing.T) {
+	cases := map[string]struct {
+		src  map[string]string
+		dst  map[string]string
+		want map[string]string
+	}{
+		"both nil": {
+			want: nil,
+		},
+		"src is nil": {
+			dst:  map[string]string{"a": "1"},
+			want: map[string]string{"a": "1"},
+		},
+		"dst is nil": {
+			src:  map[string]string{"a": "1"},
+			want: map[string]string{"a": "1"},
+		},
+		"conflict": {
+			src:  map[string]string{"a": "src", "b": "2"},
+			dst:  map[string]string{"a": "dst", "c": "3"},
+			want: map[string]string{"a": "src", "b": "2", "c": "3"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, util.MergeMapOverrideWithSrc(tc.src, tc.dst))
+		})
+	}
+	// the opposite conflict resolution of MergeMapOverrideWithDst
+	assert.Equal(t, map[string]string{"a": "dst"}, util.MergeMapOverrideWithDst(map[string]string{"a": "src"}, map[string]string{"a": "dst"}))
+	assert.Equal(t, map[string]string{"a": "src"}, util.MergeMapOverrideWithSrc(map[string]string{"a": "src"}, map[string]string{"a": "dst"}))
+}
+
+func TestMergeAnnotationsBounded(t *testing.T) {
+	const limit = 256 * 1024
+	// the size of an annotation is the length of its key plus the length of its value
+	value := func(key string, size int) string { return strings.Repeat("x", size-len(key)) }
+	cases := map[string]struct {
+		src          map[string]string
+		dst          map[string]string
+		wantErr      bool
+		wantOversize []string
+	}{
+		"small": {
+			src: map[string]string{"a": "1"},
+			dst: map[string]string{"b": "2"},
+		},
+		"exactly at the limit": {
+			src: map[string]string{"parent": value("parent", limit-1024)},
+			dst: map[string]string{"child": value("child", 1024)},
+		},
+		"one byte over the limit": {
+			src:          map[string]string{"parent": value("parent", limit-1024), "small": "1"},
+			dst:          map[string]string{"child": value("child", 1025)},
+			wantErr:      true,
+			wantOversize: []string{"parent"},
+		},
+		"overridden by dst is not counted twice": {
+			src: map[string]string{"shared": value("shared", limit)},
+			dst: map[string]string{"shared": "dst"},
+		},
+		"several oversized annotations": {
+			src:          map[string]string{"big-a": value("big-a", 100*1024), "big-b": value("big-b", 100*1024), "big-c": value("big-c", 90*1024)},
+			dst:          map[string]string{"child": value("child", 100*1024)},
+			wantErr:      true,
+			wantOversize: []string{"big-a", "big-b"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			merged, err := util.MergeAnnotationsBounded(tc.src, tc.dst)
+			if !tc.wantErr {
+				assert.NoError(t, err)
+				assert.Equal(t, util.MergeMapOverrideWithDst(tc.src, tc.dst), merged)
+				return
+			}
+			assert.Nil(t, merged)
+			assert.ErrorContains(t, err, fmt.Sprintf("oversized annotations: [%s]", strings.Join(tc.wantOversize, ",")))
+		})
+	}
+}
+
+func TestMergeMapWithDeletes(t *testing.T) {
+	cases := map[string]struct {
+		src      map[string]string
+		dst      map[string]string
+		sentinel string
+		want     map[string]string
+	}{
+		"both nil": {
+			want: nil,
+		},
+		"add": {
+			src:  map[string]string{"a": "1"},
+			dst:  map[string]string{"b": "2"},
+			want: map[string]string{"a": "1", "b": "2"},
+		},
+		"override": {
+			src:  map[string]string{"a": "1"},
+			dst:  map[string]string{"a": "2"},
+			want: map[string]string{"a": "2"},
+		},
+		"delete with default sentinel": {
+			src:  map[string]string{"a": "1", "b": "2"},
+			dst:  map[string]string{"a": "-", "c": "3"},
+			want: map[string]string{"b": "2", "c": "3"},
+		},
+		"delete missing key": {
+			src:  nil,
+			dst:  map[string]string{"a": "-"},
+			want: map[string]string{},
+		},
+		"custom sentinel": {
+			src:      map[string]string{"a": "1", "b": "2"},
+			dst:      map[string]string{"a": "-", "b": "<delete>"},
+			sentinel: "<delete>",
+			want:     map[string]string{"a": "-"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			src := util.MergeMapOverrideWithDst(tc.src, nil)
+			assert.Equal(t, tc.want, util.MergeMapWithDeletes(tc.src, tc.dst, tc.sentinel))
+			assert.Equal(t, src, util.MergeMapOverrideWithDst(tc.src, nil), "src should not be modified")
+		})
+	}
+}
+
+func TestMergeMapDeep(t *testing.T) {
+	cases := map[string]struct {
+		src  map[string]interface{}
+		dst  map[string]interface{}
+		opts []util.MergeMapDeepOption
+		want map[string]interface{}
+	}{
+		"both nil": {
+			src:  nil,
+			dst:  nil,
+			want: nil,
+		},
+		"src is nil": {
+			src:  nil,
+			dst:  map[string]interface{}{"a": "b"},
+			want: map[string]interface{}{"a": "b"},
+		},
+		"dst is nil": {
+			src:  map[string]interface{}{"a": "b"},
+			dst:  nil,
+			want: map[string]interface{}{"a": "b"},
+		},
+		"nested maps are merged and dst wins on scalars": {
+			src: map[string]interface{}{
+				"a": map[string]interface{}{"b": "src", "c": "src", "d": map[string]interface{}{"e": 1}},
+				"f": "src",
+			},
+			dst: map[string]interface{}{
+				"a": map[string]interface{}{"b": "dst", "d": map[string]interface{}{"g": 2}},
+				"h": "dst",
+			},
+			want: map[string]interface{}{
+				"a": map[string]interface{}{"b": "dst", "c": "src", "d": map[string]interface{}{"e": 1, "g": 2}},
+				"f": "src",
+				"h": "dst",
+			},
+		},
+		"map is replaced by scalar": {
+			src:  map[string]interface{}{"a": map[string]interface{}{"b": "c"}},
+			dst:  map[string]interface{}{"a": "d"},
+			want: map[string]interface{}{"a": "d"},
+		},
+		"slices are replaced by default": {
+			src:  map[string]interface{}{"a": []interface{}{"x", "y"}},
+			dst:  map[string]interface{}{"a": []interface{}{"z"}},
+			want: map[string]interface{}{"a": []interface{}{"z"}},
+		},
+		"slices are appended with option": {
+			src:  map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{"x", "y"}}},
+			dst:  map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{"z"}}},
+			opts: []util.MergeMapDeepOption{util.MergeMapDeepWithAppendSlice()},
+			want: map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{"x", "y", "z"}}},
+		},
+	}
+	for name, tc := range cases {
+		t.Log("Running test case: " + name)
+		assert.Equal(t, tc.want, util.MergeMapDeep(tc.src, tc.dst, tc.opts...))
+	}
+
+	src := map[string]interface{}{"a": map[string]interface{}{"b": "c"}}
+	_ = util.MergeMapDeep(src, map[string]interface{}{"a": map[string]interface{}{"b": "d"}})
+	assert.Equal(t, map[string]interface{}{"a": map[string]interface{}{"b": "c"}}, src)
+}
+
 func TestRawExtension2Map(t *testing.T) {
 	r1 := runtime.RawExtension{
 		Raw:    []byte(`{"a":{"c":"d"},"b":1}`),
@@ -613,6 +972,140 @@ func TestGetDefinitionWithClusterScope(t *testing.T) {
 	}
 }
 
+func TestGetDefinitionMetrics(t *testing.T) {
+	ctx := util.SetNamespaceInCtx(context.Background(), "vela-app")
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		switch key.Namespace {
+		case "vela-app", "vela-system":
+			return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "traitDefinition"}, key.Name)
+		default:
+			return nil
+		}
+	}}
+	lookups := testutil.ToFloat64(util.DefinitionLookupCounter)
+	systemFallbacks := testutil.ToFloat64(util.DefinitionSystemNamespaceFallbackCounter)
+	clusterScopeFallbacks := testutil.ToFloat64(util.DefinitionClusterScopeFallbackCounter)
+
+	assert.NoError(t, util.GetDefinition(ctx, &cli, new(v1beta1.TraitDefinition), "mock"))
+	assert.Equal(t, lookups+1, testutil.ToFloat64(util.DefinitionLookupCounter))
+	assert.Equal(t, systemFallbacks+1, testutil.ToFloat64(util.DefinitionSystemNamespaceFallbackCounter))
+	assert.Equal(t, clusterScopeFallbacks+1, testutil.ToFloat64(util.DefinitionClusterScopeFallbackCounter))
+}
+
+func TestGetDefinitionWithNamespaces(t *testing.T) {
+	ctx := context.Background()
+	var searched []string
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		searched = append(searched, key.Namespace)
+		switch key.Namespace {
+		case "org-system", "vela-system":
+			obj.SetNamespace(key.Namespace)
+			return nil
+		case "":
+			return fmt.Errorf("an empty namespace may not be set when a resource name is provided")
+		default:
+			return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "traitDefinition"}, key.Name)
+		}
+	}}
+
+	td := new(v1beta1.TraitDefinition)
+	assert.NoError(t, util.GetDefinitionWithNamespaces(ctx, &cli, td, "mock", "vela-app", "org-system", "vela-system"))
+	assert.Equal(t, "org-system", td.Namespace)
+	assert.Equal(t, []string{"vela-app", "org-system"}, searched)
+
+	searched = nil
+	err := util.GetDefinitionWithNamespaces(ctx, &cli, new(v1beta1.TraitDefinition), "mock", "vela-app", "vela-app", "team-system")
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, []string{"vela-app", "team-system", ""}, searched)
+
+	assert.Error(t, util.GetDefinitionWithNamespaces(ctx, &cli, new(v1beta1.TraitDefinition), "mock"))
+}
+
+func TestGetDefinitionInSystemNamespaceWithClusterScopeCRD(t *testing.T) {
+	var searched []string
+	// the definition crd is cluster scope in the old clusters
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		searched = append(searched, key.Namespace)
+		if key.Namespace == "" {
+			obj.SetName(key.Name)
+			return nil
+		}
+		return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "traitDefinition"}, key.Name)
+	}}
+
+	// the app namespace is the system namespace, so the cluster-scope fallback is still issued for it
+	ctx := util.SetNamespaceInCtx(context.Background(), oam.SystemDefinitionNamespace)
+	td := new(v1beta1.TraitDefinition)
+	ns, err := util.GetDefinitionResolved(ctx, &cli, td, "mock")
+	assert.NoError(t, err)
+	assert.Equal(t, "", ns)
+	assert.Equal(t, "mock", td.Name)
+	assert.Equal(t, []string{oam.SystemDefinitionNamespace, ""}, searched)
+
+	// the same for the x-definition namespace
+	searched = nil
+	ctx = util.SetXDefinitionNamespaceInCtx(util.SetNamespaceInCtx(context.Background(), "org-system"), "org-system")
+	assert.NoError(t, util.GetDefinition(ctx, &cli, new(v1beta1.TraitDefinition), "mock"))
+	assert.Equal(t, []string{"org-system", ""}, searched)
+}
+
+func TestGetDefinitionWithoutClusterScopeFallback(t *testing.T) {
+	ctx := util.SetNamespaceInCtx(context.Background(), "vela-app")
+	var searched []string
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		searched = append(searched, key.Namespace)
+		if key.Namespace == "vela-system" && key.Name == "mock" {
+			return nil
+		}
+		return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "traitDefinition"}, key.Name)
+	}}
+
+	// the cluster-scope compatibility path is enabled by default
+	err := util.GetDefinitionWithOptions(ctx, &cli, new(v1beta1.TraitDefinition), "missing")
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, []string{"vela-app", "vela-system", ""}, searched)
+
+	searched = nil
+	clusterScopeFallbacks := testutil.ToFloat64(util.DefinitionClusterScopeFallbackCounter)
+	assert.NoError(t, util.GetDefinitionWithOptions(ctx, &cli, new(v1beta1.TraitDefinition), "mock", util.WithoutClusterScopeFallback()))
+	assert.Equal(t, []string{"vela-app", "vela-system"}, searched)
+	assert.Equal(t, clusterScopeFallbacks, testutil.ToFloat64(util.DefinitionClusterScopeFallbackCounter))
+
+	searched = nil
+	err = util.GetDefinitionWithOptions(ctx, &cli, new(v1beta1.TraitDefinition), "missing", util.WithoutClusterScopeFallback())
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, []string{"vela-app", "vela-system"}, searched)
+}
+
+func TestGetDefinitionLive(t *testing.T) {
+	ctx := util.SetNamespaceInCtx(context.Background(), "vela-app")
+	newReader := func(revision string) *test.MockClient {
+		return &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+			if key.Namespace != "vela-system" {
+				return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "traitDefinition"}, key.Name)
+			}
+			obj.SetName(key.Name)
+			obj.SetNamespace(key.Namespace)
+			obj.SetResourceVersion(revision)
+			return nil
+		}}
+	}
+	staleCache, live := newReader("1"), newReader("2")
+
+	cached := new(v1beta1.TraitDefinition)
+	assert.NoError(t, util.GetDefinition(ctx, staleCache, cached, "scaler"))
+	assert.Equal(t, "1", cached.ResourceVersion)
+
+	latest := new(v1beta1.TraitDefinition)
+	assert.NoError(t, util.GetDefinitionLive(ctx, live, latest, "scaler"))
+	assert.Equal(t, "2", latest.ResourceVersion)
+	assert.Equal(t, "vela-system", latest.Namespace)
+
+	assert.True(t, apierrors.IsNotFound(util.GetDefinitionLive(ctx, &test.MockClient{
+		MockGet: test.NewMockGetFn(apierrors.NewNotFound(schema.GroupResource{}, "scaler")),
+	}, new(v1beta1.TraitDefinition), "scaler")))
+}
+
 func TestGetWorkloadDefinition(t *testing.T) {
 	// Test common variables
 	ctx := context.Background()
@@ -918,11 +1411,76 @@ func TestExtractRevisionNum(t *testing.T) {
 	}
 }
 
+func TestExtractComponentName(t *testing.T) {
+	testcases := []struct {
+		revName      string
+		wantCompName string
+	}{{
+		revName:      "myapp-v1",
+		wantCompName: "myapp",
+	}, {
+		revName:      "my-app-v10",
+		wantCompName: "my-app",
+	}, {
+		revName:      "my-app-2",
+		wantCompName: "my-app-2",
+	}, {
+		revName:      "frontend-v1-abcd",
+		wantCompName: "frontend-v1-abcd",
+	}, {
+		revName:      "frontend-6c4bd5d7f9",
+		wantCompName: "frontend",
+	}, {
+		revName:      "v1",
+		wantCompName: "v1",
+	}}
+
+	for _, tt := range testcases {
+		assert.Equal(t, tt.wantCompName, util.ExtractComponentName(tt.revName))
+	}
+}
+
+func TestExtractComponentNameWithDelimiter(t *testing.T) {
+	testcases := []struct {
+		revName      string
+		delimiter    string
+		wantCompName string
+		hasError     bool
+	}{{
+		revName:      "my-app-v3",
+		delimiter:    "-",
+		wantCompName: "my-app",
+	}, {
+		revName:      "worker@v2",
+		delimiter:    "@",
+		wantCompName: "worker",
+	}, {
+		revName:   "my-app-2",
+		delimiter: "-",
+		hasError:  true,
+	}, {
+		revName:   "worker-v2",
+		delimiter: "@",
+		hasError:  true,
+	}, {
+		revName:   "-v2",
+		delimiter: "-",
+		hasError:  true,
+	}}
+
+	for _, tt := range testcases {
+		compName, err := util.ExtractComponentNameWithDelimiter(tt.revName, tt.delimiter)
+		assert.Equal(t, tt.hasError, err != nil)
+		assert.Equal(t, tt.wantCompName, compName)
+	}
+}
+
 func TestConvertDefinitionRevName(t *testing.T) {
 	testcases := []struct {
 		defName     string
 		wantRevName string
 		hasError    bool
+		wantReason  util.InvalidRevisionNameReason
 	}{{
 		defName:     "worker@v2",
 		wantRevName: "worker-v2",
@@ -936,22 +1494,161 @@ func TestConvertDefinitionRevName(t *testing.T) {
 		wantRevName: "worker",
 		hasError:    false,
 	}, {
-		defName:  "webservice@@v2",
-		hasError: true,
+		defName:    "webservice@@v2",
+		hasError:   true,
+		wantReason: util.InvalidRevisionNameNotQualified,
 	}, {
-		defName:  "webservice@v10@v3",
-		hasError: true,
+		defName:    "webservice@v10@v3",
+		hasError:   true,
+		wantReason: util.InvalidRevisionNameMalformed,
 	}, {
-		defName:  "@v10",
-		hasError: true,
+		defName:    "@v10",
+		hasError:   true,
+		wantReason: util.InvalidRevisionNameMalformed,
+	}, {
+		defName:    "Worker_",
+		hasError:   true,
+		wantReason: util.InvalidRevisionNameNotQualified,
 	}}
 
 	for _, tt := range testcases {
 		revName, err := util.ConvertDefinitionRevName(tt.defName)
 		assert.Equal(t, tt.hasError, err != nil)
+		assert.Equal(t, tt.hasError, util.IsInvalidRevisionName(err))
 		if !tt.hasError {
 			assert.Equal(t, tt.wantRevName, revName)
+			continue
 		}
+		var invalidErr util.ErrInvalidRevisionName
+		assert.True(t, errors.As(err, &invalidErr))
+		assert.Equal(t, tt.wantReason, invalidErr.Reason)
+		assert.NotEmpty(t, invalidErr.Errs)
+		// the aggregated error of ValidateQualifiedName is wrapped
+		var agg utilerrors.Aggregate
+		assert.True(t, errors.As(err, &agg))
+		assert.Len(t, invalidErr.Errs, len(agg.Errors()))
+		assert.True(t, util.IsInvalidRevisionName(errors.Wrap(err, "wrapped")))
+	}
+}
+
+func TestSplitDefinitionReference(t *testing.T) {
+	testcases := map[string]struct {
+		name             string
+		wantBaseName     string
+		wantRevisionSpec string
+	}{
+		"no @":        {name: "worker", wantBaseName: "worker"},
+		"one @":       {name: "worker@v1.3.1", wantBaseName: "worker", wantRevisionSpec: "v1.3.1"},
+		"constraint":  {name: "worker@^1.3", wantBaseName: "worker", wantRevisionSpec: "^1.3"},
+		"two @":       {name: "worker@v1@v2", wantBaseName: "worker", wantRevisionSpec: "v1@v2"},
+		"adjacent @":  {name: "worker@@v2", wantBaseName: "worker", wantRevisionSpec: "@v2"},
+		"leading @":   {name: "@v2", wantRevisionSpec: "v2"},
+		"trailing @":  {name: "worker@", wantBaseName: "worker"},
+		"empty input": {name: ""},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			baseName, revisionSpec := util.SplitDefinitionReference(tt.name)
+			assert.Equal(t, tt.wantBaseName, baseName)
+			assert.Equal(t, tt.wantRevisionSpec, revisionSpec)
+		})
+	}
+
+	// the references with two `@` are rejected consistently
+	_, err := util.ConvertDefinitionRevName("worker@v1@v2")
+	assert.True(t, util.IsInvalidRevisionName(err))
+	_, err = util.ParseDefinitionVersion("worker@v1@v2")
+	assert.Error(t, err)
+	_, err = util.ParseDefinitionVersion("worker@^1.0@v2")
+	assert.Error(t, err)
+}
+func TestValidateQualifiedName(t *testing.T) {
+	testcases := map[string]struct {
+		name     string
+		wantErrs int
+		contains []string
+	}{
+		"valid":              {name: "webservice"},
+		"valid with prefix":  {name: "example.com/webservice"},
+		"valid with version": {name: "webservice-v1.2.3"},
+		"too long": {
+			name:     strings.Repeat("a", 64),
+			wantErrs: 1,
+			contains: []string{`name "` + strings.Repeat("a", 64) + `" is not a qualified name`, "must be no more than 63 characters"},
+		},
+		"bad character": {
+			name:     "worker@v1",
+			wantErrs: 1,
+			contains: []string{`name "worker@v1" is not a qualified name`, "alphanumeric characters"},
+		},
+		"too long with bad characters": {
+			name:     strings.Repeat("a", 63) + "_",
+			wantErrs: 2,
+			contains: []string{"must be no more than 63 characters", "alphanumeric characters"},
+		},
+		"empty": {
+			name:     "",
+			wantErrs: 2,
+		},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := util.ValidateQualifiedName(tt.name)
+			if tt.wantErrs == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var agg utilerrors.Aggregate
+			assert.True(t, errors.As(err, &agg))
+			assert.Len(t, agg.Errors(), tt.wantErrs)
+			for _, s := range tt.contains {
+				assert.ErrorContains(t, err, s)
+			}
+		})
+	}
+}
+
+func TestParseDefinitionVersion(t *testing.T) {
+	testcases := []struct {
+		defName  string
+		want     *util.DefinitionVersion
+		hasError bool
+	}{{
+		defName: "worker",
+		want:    &util.DefinitionVersion{Type: util.DefinitionVersionLatest, Name: "worker", RevisionName: "worker"},
+	}, {
+		defName: "worker@v1.3.1",
+		want:    &util.DefinitionVersion{Type: util.DefinitionVersionExact, Name: "worker", Version: "v1.3.1", RevisionName: "worker-v1.3.1"},
+	}, {
+		defName: "worker@^1.3",
+		want:    &util.DefinitionVersion{Type: util.DefinitionVersionConstraint, Name: "worker", Version: "^1.3"},
+	}, {
+		defName: "worker@>=1.2.0 <2.0.0",
+		want:    &util.DefinitionVersion{Type: util.DefinitionVersionConstraint, Name: "worker", Version: ">=1.2.0 <2.0.0"},
+	}, {
+		defName: "worker@stable",
+		want:    &util.DefinitionVersion{Type: util.DefinitionVersionChannel, Name: "worker", Version: "stable"},
+	}, {
+		defName: "worker@vnext",
+		want:    &util.DefinitionVersion{Type: util.DefinitionVersionChannel, Name: "worker", Version: "vnext"},
+	}, {
+		defName:  "worker@Canary_1",
+		hasError: true,
+	}, {
+		defName:  "worker@>=abc",
+		hasError: true,
+	}, {
+		defName:  "@stable",
+		hasError: true,
+	}, {
+		defName:  "webservice@v10@v3",
+		hasError: true,
+	}}
+
+	for _, tt := range testcases {
+		got, err := util.ParseDefinitionVersion(tt.defName)
+		assert.Equal(t, tt.hasError, err != nil, tt.defName)
+		assert.Equal(t, tt.want, got, tt.defName)
 	}
 }
 
@@ -981,6 +1678,43 @@ func TestXDefinitionNamespaceInCtx(t *testing.T) {
 	}
 }
 
+func TestResolveXDefinitionNamespace(t *testing.T) {
+	newApp := func(xNs string) *v1beta1.Application {
+		app := &v1beta1.Application{}
+		if xNs != "" {
+			app.SetAnnotations(map[string]string{oam.AnnotationXDefinitionNamespace: xNs})
+		}
+		return app
+	}
+	testcases := map[string]struct {
+		ctx  context.Context
+		app  *v1beta1.Application
+		want string
+	}{
+		"context takes precedence": {
+			ctx:  util.SetXDefinitionNamespaceInCtx(context.Background(), "ctx-system"),
+			app:  newApp("anno-system"),
+			want: "ctx-system",
+		},
+		"annotation when context is not set": {
+			ctx:  context.Background(),
+			app:  newApp("anno-system"),
+			want: "anno-system",
+		},
+		"system namespace by default": {
+			ctx:  context.Background(),
+			app:  newApp(""),
+			want: oam.SystemDefinitionNamespace,
+		},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, util.ResolveXDefinitionNamespace(tt.ctx, tt.app))
+		})
+	}
+	assert.Equal(t, oam.SystemDefinitionNamespace, util.ResolveXDefinitionNamespace(context.Background(), nil))
+}
+
 func TestGetLatestDefinitionRevisionName(t *testing.T) {
 	componetListCli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
 		defRevisionList := getComponentDefRevisionList()
@@ -1052,26 +1786,58 @@ func TestGetLatestDefinitionRevisionName(t *testing.T) {
 			expectedDefRevisionName: "",
 			client:                  &traitListCli,
 			err:                     fmt.Errorf("error finding definition revision for Name: scaler-trait, Type: Trait"),
-		},
-	}
-	ctx := context.Background()
-	for _, tc := range testcases {
-		defRevisionName, err := util.GetLatestDefinitionRevisionName(ctx, tc.client, tc.definitionName, tc.inputRevisionName, common.DefinitionType(tc.definitionType))
-		assert.Equal(t, defRevisionName, tc.expectedDefRevisionName)
-		assert.Equal(t, err, tc.err)
-	}
-}
-
-func TestGetCapabilityDefinitionComponentAutoUpdateEnabled(t *testing.T) {
-	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
-		defRevisionList := getComponentDefRevisionList()
-		defRevisionList.DeepCopyInto(list.(*v1beta1.DefinitionRevisionList))
-		return nil
-	}, MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
-		componentDefinitionRevision.DeepCopyInto(obj.(*v1beta1.DefinitionRevision))
-		return nil
-	}}
-	annotations := make(map[string]string)
+		}, {
+			name:                    "Component version range constraint specified",
+			inputRevisionName:       ">=1.2.0 <1.3.0",
+			definitionName:          "configmap-component",
+			definitionType:          "Component",
+			expectedDefRevisionName: "configmap-component-v1.2.4",
+			client:                  &componetListCli,
+			err:                     nil,
+		}, {
+			name:                    "Component caret constraint specified",
+			inputRevisionName:       "^1.2",
+			definitionName:          "configmap-component",
+			definitionType:          "Component",
+			expectedDefRevisionName: "configmap-component-v1.3.0",
+			client:                  &componetListCli,
+			err:                     nil,
+		}, {
+			name:                    "Trait tilde constraint specified",
+			inputRevisionName:       "~1.2.1",
+			definitionName:          "scaler-trait",
+			definitionType:          "Trait",
+			expectedDefRevisionName: "scaler-trait-v1.2.4",
+			client:                  &traitListCli,
+			err:                     nil,
+		}, {
+			name:                    "No revision satisfies the constraint",
+			inputRevisionName:       ">=2.0.0",
+			definitionName:          "scaler-trait",
+			definitionType:          "Trait",
+			expectedDefRevisionName: "",
+			client:                  &traitListCli,
+			err:                     fmt.Errorf("error finding definition revision for Name: scaler-trait, Type: Trait"),
+		},
+	}
+	ctx := context.Background()
+	for _, tc := range testcases {
+		defRevisionName, err := util.GetLatestDefinitionRevisionName(ctx, tc.client, tc.definitionName, tc.inputRevisionName, common.DefinitionType(tc.definitionType))
+		assert.Equal(t, defRevisionName, tc.expectedDefRevisionName)
+		assert.Equal(t, err, tc.err)
+	}
+}
+
+func TestGetCapabilityDefinitionComponentAutoUpdateEnabled(t *testing.T) {
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		defRevisionList := getComponentDefRevisionList()
+		defRevisionList.DeepCopyInto(list.(*v1beta1.DefinitionRevisionList))
+		return nil
+	}, MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		componentDefinitionRevision.DeepCopyInto(obj.(*v1beta1.DefinitionRevision))
+		return nil
+	}}
+	annotations := make(map[string]string)
 	annotations[oam.AnnotationAutoUpdate] = "true"
 	definitionName := "configmap-component@v1"
 	ctx := context.Background()
@@ -1101,6 +1867,91 @@ func TestGetCapabilityDefinitionOfTraitAutoUpdateEnabled(t *testing.T) {
 
 }
 
+// readerOnly hides all the methods of the underlying client except the ones of client.Reader
+type readerOnly struct {
+	client.Reader
+}
+
+func TestGetCapabilityDefinitionWithVersionConstraint(t *testing.T) {
+	var gotRevisionName string
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		defRevisionList := getComponentDefRevisionList()
+		defRevisionList.DeepCopyInto(list.(*v1beta1.DefinitionRevisionList))
+		return nil
+	}, MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		gotRevisionName = key.Name
+		componentDefinitionRevision.DeepCopyInto(obj.(*v1beta1.DefinitionRevision))
+		return nil
+	}}
+	ctx := context.Background()
+	definition := new(v1beta1.ComponentDefinition)
+	err := util.GetCapabilityDefinition(ctx, &cli, definition, "configmap-component@>=1.2.0 <1.3.0", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "configmap-component-v1.2.4", gotRevisionName)
+	assert.Equal(t, "1.0.0", definition.Spec.Version)
+
+	// the constraint can be resolved by a reader which is not a full client, e.g. the APIReader of the manager
+	gotRevisionName = ""
+	definition = new(v1beta1.ComponentDefinition)
+	err = util.GetCapabilityDefinition(ctx, readerOnly{Reader: &cli}, definition, "configmap-component@~1.2", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "configmap-component-v1.2.4", gotRevisionName)
+	revisionName, err := util.GetLatestDefinitionRevisionName(ctx, readerOnly{Reader: &cli}, "configmap-component", "~1.2", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "configmap-component-v1.2.4", revisionName)
+
+	err = util.GetCapabilityDefinition(ctx, &cli, definition, "configmap-component@>=abc", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid version constraint \">=abc\" for definition configmap-component")
+
+	err = util.GetCapabilityDefinition(ctx, &cli, definition, "configmap-component@stable", nil)
+	assert.EqualError(t, err, "cannot resolve channel stable of definition configmap-component")
+}
+
+func TestGetCapabilityDefinitionWithTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(tp)
+
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		defRevisionList := getComponentDefRevisionList()
+		defRevisionList.DeepCopyInto(list.(*v1beta1.DefinitionRevisionList))
+		return nil
+	}, MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		componentDefinitionRevision.DeepCopyInto(obj.(*v1beta1.DefinitionRevision))
+		return nil
+	}}
+	annotations := map[string]string{oam.AnnotationAutoUpdate: "true"}
+	err := util.GetCapabilityDefinition(context.Background(), &cli, new(v1beta1.ComponentDefinition), "configmap-component@v1", annotations)
+	assert.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	assert.Contains(t, spans, "GetLatestDefinitionRevisionName")
+	assert.Contains(t, spans, "GetDefinitionRevision")
+	root, ok := spans["GetCapabilityDefinition"]
+	assert.True(t, ok)
+	assert.Contains(t, root.Attributes(), util.AttributeDefinitionName.String("configmap-component@v1"))
+	assert.Contains(t, root.Attributes(), util.AttributeDefinitionType.String("Component"))
+	assert.Contains(t, root.Attributes(), util.AttributeDefinitionAutoUpdate.Bool(true))
+	assert.Equal(t, root.SpanContext().SpanID(), spans["GetDefinitionRevision"].Parent().SpanID())
+
+	// return directly if the context is done
+	var called bool
+	cancelledCli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		called = true
+		return nil
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = util.GetCapabilityDefinition(ctx, &cancelledCli, new(v1beta1.ComponentDefinition), "configmap-component", nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, called)
+}
+
 func TestGetCapabilityDefinitionComponentAutoUpdateDisabled(t *testing.T) {
 	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
 		componentDefinitionRevision.Spec.ComponentDefinition.DeepCopyInto(obj.(*v1beta1.ComponentDefinition))
@@ -1316,3 +2167,1977 @@ var componentDefinitionRevision = v1beta1.DefinitionRevision{
 		},
 	},
 }
+
+func TestMinMaxAbs(t *testing.T) {
+	assert.Equal(t, 1, util.Min(1, 2))
+	assert.Equal(t, 2, util.Max(1, 2))
+	assert.Equal(t, 3, util.Abs(-3))
+	assert.Equal(t, int32(-5), util.MinOf[int32](3, -5))
+	assert.Equal(t, int64(10), util.MaxOf[int64](10, 7))
+	assert.Equal(t, 0.75, util.MaxOf(0.5, 0.75))
+	assert.Equal(t, "a", util.MinOf("b", "a"))
+	assert.Equal(t, int64(7), util.AbsOf[int64](-7))
+	assert.Equal(t, 1.5, util.AbsOf(-1.5))
+	assert.Equal(t, int32(0), util.AbsOf[int32](0))
+}
+
+func TestClamp(t *testing.T) {
+	testcases := map[string]struct {
+		value, low, high int
+		want             int
+	}{
+		"below low":       {value: -1, low: 0, high: 10, want: 0},
+		"above high":      {value: 11, low: 0, high: 10, want: 10},
+		"within range":    {value: 5, low: 0, high: 10, want: 5},
+		"equal bounds":    {value: 5, low: 3, high: 3, want: 3},
+		"inverted bounds": {value: 20, low: 10, high: 0, want: 10},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, util.Clamp(tt.value, tt.low, tt.high))
+		})
+	}
+	assert.Equal(t, int32(30), util.ClampOf[int32](60, 1, 30))
+	assert.Equal(t, 0.5, util.ClampOf(0.2, 0.5, 1.0))
+}
+func TestQuantityMinMaxClamp(t *testing.T) {
+	str := func(q resource.Quantity) string { return q.String() }
+	small, large := resource.MustParse("100m"), resource.MustParse("250m")
+	assert.Equal(t, "100m", str(util.MinQuantity(small, large)))
+	assert.Equal(t, "100m", str(util.MinQuantity(large, small)))
+	assert.Equal(t, "250m", str(util.MaxQuantity(small, large)))
+	assert.Equal(t, "250m", str(util.MaxQuantity(large, small)))
+
+	gi, mi := resource.MustParse("1Gi"), resource.MustParse("512Mi")
+	assert.Equal(t, "512Mi", str(util.MinQuantity(gi, mi)))
+	assert.Equal(t, "1Gi", str(util.MaxQuantity(gi, mi)))
+	// the quantities with different units are compared by value
+	assert.Equal(t, "1Gi", str(util.MaxQuantity(gi, resource.MustParse("1000Mi"))))
+
+	testcases := map[string]struct {
+		value, low, high string
+		want             string
+	}{
+		"below low":       {value: "50m", low: "100m", high: "250m", want: "100m"},
+		"above high":      {value: "2Gi", low: "512Mi", high: "1Gi", want: "1Gi"},
+		"within range":    {value: "768Mi", low: "512Mi", high: "1Gi", want: "768Mi"},
+		"inverted bounds": {value: "1", low: "250m", high: "100m", want: "250m"},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			got := util.ClampQuantity(resource.MustParse(tt.value), resource.MustParse(tt.low), resource.MustParse(tt.high))
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	assert.Equal(t, "250m", str(util.AbsQuantity(resource.MustParse("-250m"))))
+	assert.Equal(t, "1Gi", str(util.AbsQuantity(gi)))
+
+	// the results are copies, modifying them doesn't affect the inputs
+	got := util.MinQuantity(small, large)
+	got.Add(resource.MustParse("1"))
+	assert.Equal(t, "100m", small.String())
+}
+
+func TestGVKNamespaceAccessor(t *testing.T) {
+	newObj := func(apiVersion, kind, namespace string) client.Object {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		return obj
+	}
+	accessor := util.NewGVKNamespaceAccessor("app", "override", map[string]string{
+		"ConfigMap":       "app",
+		"Deployment.apps": "workloads",
+		"Deployment":      "others",
+	})
+	assert.Equal(t, "app", accessor.For(newObj("v1", "ConfigMap", "")))
+	assert.Equal(t, "workloads", accessor.For(newObj("apps/v1", "Deployment", "")))
+	assert.Equal(t, "others", accessor.For(newObj("example.com/v1", "Deployment", "")))
+	assert.Equal(t, "override", accessor.For(newObj("v1", "Secret", "original")))
+	assert.Equal(t, "override", accessor.Namespace())
+
+	accessor = util.NewGVKNamespaceAccessor("app", "", map[string]string{"ConfigMap": "configs"})
+	assert.Equal(t, "configs", accessor.For(newObj("v1", "ConfigMap", "original")))
+	assert.Equal(t, "original", accessor.For(newObj("v1", "Secret", "original")))
+	assert.Equal(t, "app", accessor.For(newObj("v1", "Secret", "")))
+	assert.Equal(t, "app", accessor.For(&corev1.ConfigMap{}))
+	assert.Equal(t, "app", accessor.Namespace())
+}
+
+func TestNewValidatedApplicationResourceNamespaceAccessor(t *testing.T) {
+	testcases := map[string]struct {
+		appNs      string
+		overrideNs string
+		hasError   bool
+	}{
+		"valid":                   {appNs: "default", overrideNs: "vela-system"},
+		"no override":             {appNs: "default"},
+		"empty app namespace":     {appNs: "", hasError: true},
+		"uppercase app namespace": {appNs: "Default", hasError: true},
+		"underscore override":     {appNs: "default", overrideNs: "vela_system", hasError: true},
+		"too long override":       {appNs: "default", overrideNs: strings.Repeat("a", 64), hasError: true},
+		"leading dash override":   {appNs: "default", overrideNs: "-ns", hasError: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			accessor, err := util.NewValidatedApplicationResourceNamespaceAccessor(tt.appNs, tt.overrideNs)
+			assert.Equal(t, tt.hasError, err != nil)
+			if !tt.hasError {
+				assert.NotNil(t, accessor)
+			}
+		})
+	}
+}
+
+func TestLabelNamespaceAccessor(t *testing.T) {
+	newObj := func(labels map[string]string) client.Object {
+		obj := &unstructured.Unstructured{}
+		obj.SetLabels(labels)
+		return obj
+	}
+	accessor := util.NewLabelNamespaceAccessor("app", "tenant", "tenant-%s")
+	testcases := map[string]struct {
+		labels map[string]string
+		want   string
+	}{
+		"present label":     {labels: map[string]string{"tenant": "foo"}, want: "tenant-foo"},
+		"absent label":      {labels: map[string]string{"team": "foo"}, want: "app"},
+		"empty label value": {labels: map[string]string{"tenant": ""}, want: "app"},
+		"no labels":         {want: "app"},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, accessor.For(newObj(tt.labels)))
+		})
+	}
+	assert.Equal(t, "app", accessor.Namespace())
+	assert.Equal(t, "foo", util.NewLabelNamespaceAccessor("app", "tenant", "").For(newObj(map[string]string{"tenant": "foo"})))
+}
+func TestAllowlistNamespaceAccessor(t *testing.T) {
+	newObj := func(namespace string) client.Object {
+		obj := &unstructured.Unstructured{}
+		obj.SetKind("ConfigMap")
+		obj.SetName("cm")
+		obj.SetNamespace(namespace)
+		return obj
+	}
+	accessor := util.NewAllowlistNamespaceAccessor(util.NewApplicationResourceNamespaceAccessor("app", ""), []string{"app", "shared"})
+	testcases := map[string]struct {
+		namespace string
+		want      string
+		allowed   bool
+	}{
+		"default namespace":    {namespace: "", want: "app", allowed: true},
+		"allowed namespace":    {namespace: "shared", want: "shared", allowed: true},
+		"disallowed namespace": {namespace: "kube-system", want: "app", allowed: false},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			ns, err := accessor.ForChecked(newObj(tt.namespace))
+			if tt.allowed {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, ns)
+			} else {
+				assert.ErrorContains(t, err, `namespace "kube-system" of ConfigMap cm is not in the allowed namespaces`)
+				assert.Empty(t, ns)
+			}
+			assert.Equal(t, tt.want, accessor.For(newObj(tt.namespace)))
+		})
+	}
+	assert.Equal(t, "app", accessor.Namespace())
+
+	accessor = util.NewAllowlistNamespaceAccessor(util.NewApplicationResourceNamespaceAccessor("app", "override"), []string{"app"})
+	_, err := accessor.ForChecked(newObj("app"))
+	assert.Error(t, err)
+}
+
+func TestPassLabelAndAnnotationFiltered(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetLabels(map[string]string{
+		"app.oam.dev/name": "app",
+		"team":             "core",
+		"internal/id":      "1",
+	})
+	parent.SetAnnotations(map[string]string{
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		"app.oam.dev/context":                              "huge",
+		"app.oam.dev/publishVersion":                       "v1",
+		"description":                                      "demo",
+	})
+	child := &unstructured.Unstructured{}
+	child.SetLabels(map[string]string{"team": "child", "existing": "true"})
+	util.PassLabelAndAnnotationFiltered(parent, child, util.PassLabelAndAnnotationOptions{
+		Labels: util.KeyFilter{DeniedPrefixes: []string{"internal/"}},
+		Annotations: util.KeyFilter{
+			AllowedPrefixes: []string{"app.oam.dev/"},
+			AllowedKeys:     []string{"description"},
+			DeniedKeys:      []string{"app.oam.dev/context"},
+		},
+	})
+	assert.Equal(t, map[string]string{"app.oam.dev/name": "app", "team": "core", "existing": "true"}, child.GetLabels())
+	assert.Equal(t, map[string]string{"app.oam.dev/publishVersion": "v1", "description": "demo"}, child.GetAnnotations())
+
+	// empty filters pass through everything like PassLabelAndAnnotation
+	filtered, passed := &unstructured.Unstructured{}, &unstructured.Unstructured{}
+	util.PassLabelAndAnnotationFiltered(parent, filtered, util.PassLabelAndAnnotationOptions{})
+	util.PassLabelAndAnnotation(parent, passed)
+	assert.Equal(t, passed.GetLabels(), filtered.GetLabels())
+	assert.Equal(t, passed.GetAnnotations(), filtered.GetAnnotations())
+}
+
+func TestAddLabelsProtected(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetLabels(map[string]string{
+		oam.LabelAppComponent: "comp",
+		oam.LabelAppName:      "app",
+		"team":                "core",
+	})
+	skipped := util.AddLabelsProtected(obj, map[string]string{
+		oam.LabelAppComponent: "hijacked",
+		oam.LabelAppName:      "app",
+		oam.LabelAppNamespace: "default",
+		"team":                "user",
+	}, []string{oam.LabelAppComponent, oam.LabelAppName, oam.LabelAppNamespace})
+	assert.Equal(t, []string{oam.LabelAppComponent}, skipped)
+	assert.Equal(t, map[string]string{
+		oam.LabelAppComponent: "comp",
+		oam.LabelAppName:      "app",
+		oam.LabelAppNamespace: "default",
+		"team":                "user",
+	}, obj.GetLabels())
+}
+
+func TestReconcileBackoffDuration(t *testing.T) {
+	backoff := util.ReconcileBackoff{Base: time.Second, Max: 10 * time.Second}
+	assert.Equal(t, time.Second, backoff.Duration(1))
+	assert.Equal(t, 2*time.Second, backoff.Duration(2))
+	assert.Equal(t, 8*time.Second, backoff.Duration(4))
+	assert.Equal(t, 10*time.Second, backoff.Duration(5))
+	assert.Equal(t, 10*time.Second, backoff.Duration(100))
+	assert.Equal(t, util.DefaultReconcileBackoff.Base, util.ReconcileBackoff{}.Duration(1))
+	assert.Equal(t, util.DefaultReconcileBackoff.Max, util.ReconcileBackoff{}.Duration(1000))
+}
+
+func TestEndReconcileWithNegativeConditionBackoff(t *testing.T) {
+	cond := condition.Condition{Type: "test", Reason: "reason", Message: "error msg"}
+	var writeCount, statusPatchCount int
+	countWrite := func() error {
+		writeCount++
+		return nil
+	}
+	cli := &test.MockClient{
+		MockPatch: func(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return countWrite()
+		},
+		MockUpdate: func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			return countWrite()
+		},
+		MockStatusPatch: func(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			statusPatchCount++
+			return nil
+		},
+	}
+	backoff := util.ReconcileBackoff{Base: time.Second, Max: 4 * time.Second}
+	tracker := util.NewReconcileFailureTracker()
+	workload := &mock.Target{}
+	workload.SetUID("uid")
+	ctx := context.Background()
+
+	// the condition is changed for the first time
+	result, err := util.EndReconcileWithNegativeConditionBackoff(ctx, cli, workload, tracker, backoff, cond)
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+	assert.Equal(t, 0, tracker.Failures(workload))
+
+	// the backoff grows with the consecutive failures and is capped
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		result, err = util.EndReconcileWithNegativeConditionBackoff(ctx, cli, workload, tracker, backoff, cond)
+		assert.NoError(t, err)
+		assert.Equal(t, want, result.RequeueAfter)
+		assert.Equal(t, i+1, tracker.Failures(workload))
+	}
+	// the failures issue no write other than the status patch, so they don't trigger reconciles by themselves
+	assert.Equal(t, 0, writeCount)
+	assert.Equal(t, 5, statusPatchCount)
+	assert.Empty(t, workload.GetAnnotations())
+
+	// the failures of other resources are tracked separately
+	other := &mock.Target{}
+	other.SetUID("other")
+	assert.Equal(t, 0, tracker.Failures(other))
+
+	// the failure count is reset once the condition is changed
+	result, err = util.EndReconcileWithNegativeConditionBackoff(ctx, cli, workload, tracker, backoff, cond.WithMessage("new error msg"))
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+	assert.Equal(t, 0, tracker.Failures(workload))
+	assert.Equal(t, 0, writeCount)
+
+	result, err = util.EndReconcileWithNegativeConditionBackoff(ctx, cli, workload, tracker, backoff)
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+
+	// without tracker, the resource is requeued after the fixed base delay
+	result, err = util.EndReconcileWithNegativeConditionBackoff(ctx, cli, workload, nil, backoff, cond)
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+	for i := 0; i < 3; i++ {
+		result, err = util.EndReconcileWithNegativeConditionBackoff(ctx, cli, workload, nil, backoff, cond)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Second, result.RequeueAfter)
+	}
+	result, err = util.EndReconcileWithNegativeConditionBackoff(ctx, cli, workload, nil, backoff, cond.WithMessage("another error msg"))
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+	assert.Equal(t, 0, writeCount)
+
+	cli.MockStatusPatch = test.NewMockSubResourcePatchFn(fmt.Errorf("eww"))
+	_, err = util.EndReconcileWithNegativeConditionBackoff(ctx, cli, workload, tracker, backoff, cond)
+	assert.Error(t, err)
+}
+
+type generationTarget struct {
+	mock.Target
+	ObservedGeneration int64
+}
+
+func (g *generationTarget) SetObservedGeneration(generation int64) {
+	g.ObservedGeneration = generation
+}
+
+func (g *generationTarget) DeepCopyObject() runtime.Object {
+	return &generationTarget{Target: *g.Target.DeepCopyObject().(*mock.Target), ObservedGeneration: g.ObservedGeneration}
+}
+
+func TestPatchConditionObservedGeneration(t *testing.T) {
+	cli := &test.MockClient{MockStatusPatch: test.NewMockSubResourcePatchFn(nil)}
+	ctx := context.Background()
+	cond := condition.Condition{Type: "test", Status: corev1.ConditionTrue}
+
+	workload := &generationTarget{}
+	workload.SetGeneration(3)
+	assert.NoError(t, util.PatchCondition(ctx, cli, workload, cond))
+	assert.Equal(t, int64(3), workload.ObservedGeneration)
+
+	workload.SetGeneration(4)
+	assert.NoError(t, util.EndReconcileWithPositiveCondition(ctx, cli, workload, cond))
+	assert.Equal(t, int64(4), workload.ObservedGeneration)
+
+	// objects without observedGeneration are unaffected
+	target := &mock.Target{}
+	target.SetGeneration(5)
+	assert.NoError(t, util.PatchCondition(ctx, cli, target, cond))
+	assert.Equal(t, cond, target.GetCondition("test"))
+}
+func TestPatchConditionIfChanged(t *testing.T) {
+	var patches int
+	cli := &test.MockClient{MockStatusPatch: func(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+		patches++
+		return nil
+	}}
+	ctx := context.Background()
+	ready := condition.Condition{Type: "Ready", Status: corev1.ConditionTrue, Reason: "Available"}
+
+	target := &mock.Target{}
+	patched, err := util.PatchConditionIfChanged(ctx, cli, target, ready)
+	assert.NoError(t, err)
+	assert.True(t, patched)
+	assert.Equal(t, 1, patches)
+
+	// unchanged conditions are skipped
+	patched, err = util.PatchConditionIfChanged(ctx, cli, target, ready)
+	assert.NoError(t, err)
+	assert.False(t, patched)
+	assert.Equal(t, 1, patches)
+
+	notReady := condition.Condition{Type: "Ready", Status: corev1.ConditionFalse, Reason: "Unavailable"}
+	patched, err = util.PatchConditionIfChanged(ctx, cli, target, notReady)
+	assert.NoError(t, err)
+	assert.True(t, patched)
+	assert.Equal(t, 2, patches)
+	assert.Equal(t, corev1.ConditionFalse, target.GetCondition("Ready").Status)
+
+	patched, err = util.PatchConditionIfChanged(ctx, cli, target)
+	assert.NoError(t, err)
+	assert.False(t, patched)
+
+	// the change of observedGeneration is patched even if the conditions are unchanged
+	workload := &generationTarget{}
+	workload.SetGeneration(1)
+	_, err = util.PatchConditionIfChanged(ctx, cli, workload, ready)
+	assert.NoError(t, err)
+	workload.SetGeneration(2)
+	patches = 0
+	patched, err = util.PatchConditionIfChanged(ctx, cli, workload, ready)
+	assert.NoError(t, err)
+	assert.True(t, patched)
+	assert.Equal(t, int64(2), workload.ObservedGeneration)
+	patched, err = util.PatchConditionIfChanged(ctx, cli, workload, ready)
+	assert.NoError(t, err)
+	assert.False(t, patched)
+	assert.Equal(t, 1, patches)
+
+	cli.MockStatusPatch = test.NewMockSubResourcePatchFn(errors.New("boom"))
+	patched, err = util.PatchConditionIfChanged(ctx, cli, &mock.Target{}, ready)
+	assert.True(t, patched)
+	assert.Error(t, err)
+}
+
+func TestPatchConditions(t *testing.T) {
+	var mu sync.Mutex
+	patched := map[string]bool{}
+	cli := &test.MockClient{MockStatusPatch: func(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+		if obj.GetName() == "bad" {
+			return fmt.Errorf("eww")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		patched[obj.GetName()] = true
+		return nil
+	}}
+	var objects []util.ConditionedObject
+	for _, name := range []string{"a", "bad", "b", "c"} {
+		objects = append(objects, &mock.Target{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+	}
+	cond := condition.Condition{Type: "test", Status: corev1.ConditionFalse, Reason: "reason"}
+
+	err := util.PatchConditions(context.Background(), cli, objects, cond)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "default/bad")
+	assert.NotContains(t, err.Error(), "default/a")
+	assert.Equal(t, map[string]bool{"a": true, "b": true, "c": true}, patched)
+	for _, obj := range objects {
+		assert.Equal(t, cond, obj.GetCondition("test"))
+	}
+
+	assert.NoError(t, util.PatchConditions(context.Background(), cli, objects[:1], cond))
+}
+
+func TestDiffConditions(t *testing.T) {
+	workload := &mock.Target{ConditionedStatus: condition.ConditionedStatus{Conditions: []condition.Condition{
+		{Type: "Ready", Status: corev1.ConditionFalse, Reason: "Creating"},
+		{Type: "Synced", Status: corev1.ConditionTrue, Reason: "Available"},
+		{Type: "Stale", Status: corev1.ConditionTrue, Reason: "Old"},
+	}}}
+	changes := util.DiffConditions([]condition.Condition{
+		{Type: "Ready", Status: corev1.ConditionTrue, Reason: "Available"},
+		{Type: "Synced", Status: corev1.ConditionTrue, Reason: "Available"},
+		{Type: "Healthy", Status: corev1.ConditionTrue, Reason: "Available"},
+	}, workload)
+	assert.Equal(t, []util.ConditionChange{{
+		Type: "Ready", Change: util.ConditionModified,
+		OldStatus: corev1.ConditionFalse, NewStatus: corev1.ConditionTrue, OldReason: "Creating", NewReason: "Available",
+	}, {
+		Type: "Healthy", Change: util.ConditionAdded,
+		NewStatus: corev1.ConditionTrue, NewReason: "Available",
+	}, {
+		Type: "Stale", Change: util.ConditionRemoved,
+		OldStatus: corev1.ConditionTrue, OldReason: "Old",
+	}}, changes)
+	assert.Equal(t, "Modified condition Ready: False(Creating) -> True(Available)", changes[0].String())
+
+	// removed conditions alone are not regarded as changed
+	assert.False(t, util.IsConditionChanged([]condition.Condition{
+		{Type: "Synced", Status: corev1.ConditionTrue, Reason: "Available"},
+	}, workload))
+	assert.True(t, util.IsConditionChanged([]condition.Condition{
+		{Type: "Healthy", Status: corev1.ConditionTrue, Reason: "Available"},
+	}, workload))
+}
+
+func TestYAMLConversions(t *testing.T) {
+	const large int64 = 9007199254740993 // 2^53 + 1 cannot be represented by float64
+	obj := struct {
+		Name    string  `json:"name"`
+		Size    int64   `json:"size"`
+		Percent float64 `json:"percent"`
+	}{Name: "test", Size: large, Percent: 0.5}
+
+	m, err := util.Object2MapYAML(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "test", "size": large, "percent": 0.5}, m)
+	jsonMap, err := util.Object2Map(obj)
+	assert.NoError(t, err)
+	// the JSON conversion rounds the large integer
+	assert.NotEqual(t, large, int64(jsonMap["size"].(float64)))
+
+	u, err := util.Object2UnstructuredYAML(obj)
+	assert.NoError(t, err)
+	size, found, err := unstructured.NestedInt64(u.Object, "size")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, large, size)
+
+	m, err = util.RawExtension2MapYAML(&runtime.RawExtension{Raw: []byte("name: test\nsize: 9007199254740993\nlist:\n- a\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "test", "size": large, "list": []interface{}{"a"}}, m)
+
+	m, err = util.RawExtension2MapYAML(&runtime.RawExtension{Raw: []byte(`{"size":9007199254740993}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, large, m["size"])
+
+	m, err = util.RawExtension2MapYAML(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestObject2UnstructuredWithGVK(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	u, err := util.Object2Unstructured(cm)
+	assert.NoError(t, err)
+	assert.Equal(t, "", u.GetKind())
+
+	u, err = util.Object2UnstructuredWithGVK(cm, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", u.GetAPIVersion())
+	assert.Equal(t, "ConfigMap", u.GetKind())
+	assert.Equal(t, "test", u.GetName())
+	data, _, _ := unstructured.NestedStringMap(u.Object, "data")
+	assert.Equal(t, map[string]string{"key": "value"}, data)
+
+	// the explicit GVK overrides the one in the object
+	deploy := &unstructured.Unstructured{}
+	deploy.SetAPIVersion("apps/v1beta1")
+	deploy.SetKind("Deployment")
+	u, err = util.Object2UnstructuredWithGVK(deploy, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	assert.NoError(t, err)
+	assert.Equal(t, "apps/v1", u.GetAPIVersion())
+}
+
+func TestRawExtensions2UnstructuredList(t *testing.T) {
+	testcases := map[string]struct {
+		raw       *runtime.RawExtension
+		wantNames []string
+		hasError  bool
+	}{
+		"nil":           {raw: nil},
+		"single object": {raw: &runtime.RawExtension{Raw: []byte(` {"kind":"ConfigMap","metadata":{"name":"a"}}`)}, wantNames: []string{"a"}},
+		"array": {
+			raw:       &runtime.RawExtension{Raw: []byte(`[{"kind":"ConfigMap","metadata":{"name":"a"}},{"kind":"Secret","metadata":{"name":"b"}}]`)},
+			wantNames: []string{"a", "b"},
+		},
+		"empty array":     {raw: &runtime.RawExtension{Raw: []byte(`[]`)}, wantNames: []string{}},
+		"mixed array":     {raw: &runtime.RawExtension{Raw: []byte(`[{"kind":"ConfigMap"},"str"]`)}, hasError: true},
+		"null in array":   {raw: &runtime.RawExtension{Raw: []byte(`[null]`)}, hasError: true},
+		"malformed":       {raw: &runtime.RawExtension{Raw: []byte(`{"kind":`)}, hasError: true},
+		"malformed array": {raw: &runtime.RawExtension{Raw: []byte(`[{"kind":"ConfigMap"}`)}, hasError: true},
+		"scalar":          {raw: &runtime.RawExtension{Raw: []byte(`"str"`)}, hasError: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			us, err := util.RawExtensions2UnstructuredList(tt.raw)
+			assert.Equal(t, tt.hasError, err != nil)
+			if tt.hasError {
+				return
+			}
+			if tt.wantNames == nil {
+				assert.Nil(t, us)
+				return
+			}
+			names := []string{}
+			for _, u := range us {
+				names = append(names, u.GetName())
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+func TestGetGVKFromDefinitionAmbiguous(t *testing.T) {
+	mapper := mock.NewClient(nil, map[schema.GroupVersionResource][]schema.GroupVersionKind{
+		{Resource: "clusters"}: {
+			{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "Cluster"},
+			{Group: "cluster.core.oam.dev", Version: "v1alpha1", Kind: "Cluster"},
+		},
+		{Resource: "widgets", Group: "example.com"}: {
+			{Group: "example.com", Version: "v2", Kind: "Widget"},
+			{Group: "example.com", Version: "v1", Kind: "Widget"},
+		},
+	}).RESTMapper()
+
+	_, err := util.GetGVKFromDefinition(mapper, common.DefinitionReference{Name: "clusters"})
+	assert.True(t, meta.IsAmbiguousError(err))
+	assert.Contains(t, err.Error(), "cluster.x-k8s.io")
+	assert.Contains(t, err.Error(), "cluster.core.oam.dev")
+
+	gvk, err := util.GetGVKFromDefinition(mapper, common.DefinitionReference{Name: "clusters"}, "example.com", "cluster.core.oam.dev")
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.GroupVersionKind{Group: "cluster.core.oam.dev", Version: "v1alpha1", Kind: "Cluster"}, gvk)
+
+	// kinds of multiple versions are not ambiguous
+	gvk, err = util.GetGVKFromDefinition(mapper, common.DefinitionReference{Name: "widgets.example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.GroupVersionKind{Group: "example.com", Version: "v2", Kind: "Widget"}, gvk)
+
+	// the reference without group prefers the core resource
+	knativeGV := schema.GroupVersion{Group: "serving.knative.dev", Version: "v1"}
+	coreMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{knativeGV, corev1.SchemeGroupVersion})
+	coreMapper.Add(knativeGV.WithKind("Service"), meta.RESTScopeNamespace)
+	coreMapper.Add(corev1.SchemeGroupVersion.WithKind("Service"), meta.RESTScopeNamespace)
+	gvk, err = util.GetGVKFromDefinition(coreMapper, common.DefinitionReference{Name: "services"})
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.GroupVersionKind{Version: "v1", Kind: "Service"}, gvk)
+	gvk, err = util.GetGVKFromDefinition(coreMapper, common.DefinitionReference{Name: "services"}, "serving.knative.dev")
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.GroupVersionKind{Group: "serving.knative.dev", Version: "v1", Kind: "Service"}, gvk)
+}
+
+func TestCoreResourceDefinitionName(t *testing.T) {
+	mapper := mock.NewClient(nil, nil).RESTMapper()
+	for _, kind := range []string{"Service", "ConfigMap"} {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+		name, err := util.GetDefinitionName(mapper, u, "")
+		assert.NoError(t, err)
+		ref, err := util.ConvertWorkloadGVK2Definition(mapper, common.WorkloadGVK{APIVersion: "v1", Kind: kind})
+		assert.NoError(t, err)
+		assert.Equal(t, ref.Name, name)
+		assert.Equal(t, schema.GroupResource{Resource: name}, schema.ParseGroupResource(name))
+	}
+}
+
+func TestDefinitionNameToGVK(t *testing.T) {
+	knativeGV := schema.GroupVersion{Group: "serving.knative.dev", Version: "v1"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{corev1.SchemeGroupVersion, appsv1.SchemeGroupVersion, knativeGV})
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Service"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(knativeGV.WithKind("Service"), meta.RESTScopeNamespace)
+	testcases := map[string]struct {
+		definitionName string
+		version        string
+		want           schema.GroupVersionKind
+		noMatch        bool
+	}{
+		"apps deployment": {definitionName: "deployments.apps", version: "v1", want: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}},
+		"core service":    {definitionName: "services", version: "v1", want: schema.GroupVersionKind{Version: "v1", Kind: "Service"}},
+		"no version":      {definitionName: "configmaps", want: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}},
+		"knative service": {definitionName: "services.serving.knative.dev", want: schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1", Kind: "Service"}},
+		"not found":       {definitionName: "foos.example.com", version: "v1", noMatch: true},
+		"empty":           {definitionName: "", noMatch: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			gvk, err := util.DefinitionNameToGVK(mapper, tt.definitionName, tt.version)
+			if tt.noMatch {
+				assert.True(t, meta.IsNoMatchError(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, gvk)
+		})
+	}
+}
+
+func TestHasNamespaceInCtx(t *testing.T) {
+	ctx := context.Background()
+	_, ok := util.HasAppNamespace(ctx)
+	assert.False(t, ok)
+	_, ok = util.HasXDefinitionNamespace(ctx)
+	assert.False(t, ok)
+
+	ns, ok := util.HasAppNamespace(util.SetNamespaceInCtx(ctx, "app"))
+	assert.True(t, ok)
+	assert.Equal(t, "app", ns)
+	ns, ok = util.HasAppNamespace(util.SetNamespaceInCtx(ctx, ""))
+	assert.True(t, ok)
+	assert.Equal(t, "default", ns)
+
+	ns, ok = util.HasXDefinitionNamespace(util.SetXDefinitionNamespaceInCtx(ctx, oam.SystemDefinitionNamespace))
+	assert.True(t, ok)
+	assert.Equal(t, oam.SystemDefinitionNamespace, ns)
+	ns, ok = util.HasXDefinitionNamespace(util.SetXDefinitionNamespaceInCtx(ctx, "my-vela-system"))
+	assert.True(t, ok)
+	assert.Equal(t, "my-vela-system", ns)
+}
+
+func TestSetNamespaceInCtxValidated(t *testing.T) {
+	testcases := map[string]struct {
+		namespace string
+		want      string
+		hasError  bool
+	}{
+		"empty":      {namespace: "", want: "default"},
+		"valid":      {namespace: "my-app", want: "my-app"},
+		"uppercase":  {namespace: "MyApp", hasError: true},
+		"underscore": {namespace: "my_app", hasError: true},
+		"too long":   {namespace: strings.Repeat("a", 64), hasError: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			ctx, err := util.SetNamespaceInCtxValidated(context.Background(), tt.namespace)
+			assert.Equal(t, tt.hasError, err != nil)
+			ns, ok := util.HasAppNamespace(ctx)
+			assert.Equal(t, !tt.hasError, ok)
+			assert.Equal(t, tt.want, ns)
+		})
+	}
+}
+
+func TestParseWorkloadType(t *testing.T) {
+	for _, s := range []string{"ComponentDef", "KubeDef", "HelmDef", "TerraformDef", "ReferWorkload"} {
+		wt, err := util.ParseWorkloadType(s)
+		assert.NoError(t, err)
+		assert.Equal(t, s, wt.String())
+		assert.True(t, util.IsValidWorkloadType(wt))
+	}
+	_, err := util.ParseWorkloadType("HELMDef")
+	assert.EqualError(t, err, `unknown workload type "HELMDef", valid values are: ComponentDef, KubeDef, HelmDef, TerraformDef, ReferWorkload`)
+	assert.False(t, util.IsValidWorkloadType("Unknown"))
+	assert.Equal(t, 5, len(util.ValidWorkloadTypes()))
+}
+
+func TestAsOwnerWithOptions(t *testing.T) {
+	r := &corev1.ObjectReference{APIVersion: "core.oam.dev/v1beta1", Kind: "Application", Name: "app", UID: "uid"}
+	ref := util.AsOwner(r)
+	assert.Nil(t, ref.Controller)
+	assert.Nil(t, ref.BlockOwnerDeletion)
+
+	ref = util.AsControllerWithBlockOwnerDeletion(r)
+	assert.Equal(t, "app", ref.Name)
+	assert.Equal(t, types.UID("uid"), ref.UID)
+	assert.True(t, *ref.Controller)
+	assert.True(t, *ref.BlockOwnerDeletion)
+
+	ref = util.AsOwnerWithOptions(r, util.WithBlockOwnerDeletion(true))
+	assert.Nil(t, ref.Controller)
+	assert.True(t, *ref.BlockOwnerDeletion)
+
+	ref = util.AsOwnerWithOptions(r, util.WithController(true), util.WithBlockOwnerDeletion(false))
+	assert.True(t, *ref.Controller)
+	assert.False(t, *ref.BlockOwnerDeletion)
+
+	// the options do not share the pointers
+	refs := []metav1.OwnerReference{util.AsControllerWithBlockOwnerDeletion(r), util.AsControllerWithBlockOwnerDeletion(r)}
+	*refs[0].Controller = false
+	assert.True(t, *refs[1].Controller)
+}
+
+func TestAsOwnerFromObject(t *testing.T) {
+	app := &v1beta1.Application{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: v1beta1.ApplicationKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "app-uid"},
+	}
+	ref, err := util.AsOwnerFromObject(app)
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.OwnerReference{APIVersion: "core.oam.dev/v1beta1", Kind: "Application", Name: "app", UID: "app-uid"}, ref)
+
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("apps/v1")
+	u.SetKind("Deployment")
+	u.SetName("deploy")
+	u.SetUID("deploy-uid")
+	ref, err = util.AsOwnerFromObject(u)
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "deploy", UID: "deploy-uid"}, ref)
+
+	_, err = util.AsOwnerFromObject(&v1beta1.Application{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+	assert.Error(t, err)
+	_, err = util.AsOwnerFromObject(&unstructured.Unstructured{})
+	assert.Error(t, err)
+}
+
+func TestControllerRef(t *testing.T) {
+	controllerRef := util.AsController(&corev1.ObjectReference{APIVersion: "core.oam.dev/v1beta1", Kind: "Application", Name: "app", UID: "app-uid"})
+	otherControllerRef := util.AsController(&corev1.ObjectReference{Name: "other", UID: "other-uid"})
+	testcases := map[string]struct {
+		ownerRefs    []metav1.OwnerReference
+		wantRef      *metav1.OwnerReference
+		isControlled bool
+	}{
+		"no owner": {},
+		"matching controller": {
+			ownerRefs:    []metav1.OwnerReference{util.AsOwner(&corev1.ObjectReference{Name: "other", UID: "other-uid"}), controllerRef},
+			wantRef:      &controllerRef,
+			isControlled: true,
+		},
+		"non-controller owner": {
+			ownerRefs: []metav1.OwnerReference{util.AsOwner(&corev1.ObjectReference{Name: "app", UID: "app-uid"})},
+		},
+		"other controller": {
+			ownerRefs: []metav1.OwnerReference{otherControllerRef},
+			wantRef:   &otherControllerRef,
+		},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			child := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "child", OwnerReferences: tt.ownerRefs}}
+			assert.Equal(t, tt.wantRef, util.FindControllerRef(child))
+			assert.Equal(t, tt.isControlled, util.IsControlledBy(child, "app-uid"))
+		})
+	}
+}
+
+func TestExtractRevisionNumFlexible(t *testing.T) {
+	testcases := map[string]struct {
+		revName    string
+		delimiters []string
+		want       int
+		hasError   bool
+	}{
+		"trailing suffix":     {revName: "myapp-v3-suffix", want: 3},
+		"dot delimiter":       {revName: "myapp.v4", want: 4},
+		"mixed delimiters":    {revName: "my-app.v5-canary", want: 5},
+		"last revision token": {revName: "myapp-v1-v2-vv", want: 2},
+		"custom delimiter":    {revName: "worker@v6", delimiters: []string{"@"}, want: 6},
+		"no delimiter":        {revName: "v1", hasError: true},
+		"no revision token":   {revName: "myapp-a1-b2", hasError: true},
+		"name only":           {revName: "v7-suffix", hasError: true},
+		"not a number":        {revName: "myapp-vx", hasError: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			revision, err := util.ExtractRevisionNumFlexible(tt.revName, tt.delimiters...)
+			assert.Equal(t, tt.hasError, err != nil)
+			assert.Equal(t, tt.want, revision)
+		})
+	}
+}
+
+func TestCompareRevisionNames(t *testing.T) {
+	testcases := map[string]struct {
+		a, b     string
+		want     int
+		hasError bool
+	}{
+		"equal numbers":      {a: "app-v2", b: "other-v2", want: 0},
+		"older":              {a: "app-v2", b: "app-v10", want: -1},
+		"newer":              {a: "app-v10", b: "app-v9", want: 1},
+		"unparseable first":  {a: "app-a1", b: "app-v1", hasError: true},
+		"unparseable second": {a: "app-v1", b: "app", hasError: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			got, err := util.CompareRevisionNames(tt.a, tt.b, "-")
+			assert.Equal(t, tt.hasError, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	names := []string{"app-v10", "app-v2", "app-v1"}
+	sort.Slice(names, func(i, j int) bool {
+		c, _ := util.CompareRevisionNames(names[i], names[j], "-")
+		return c < 0
+	})
+	assert.Equal(t, []string{"app-v1", "app-v2", "app-v10"}, names)
+}
+
+func TestGetDefinitionResolved(t *testing.T) {
+	testcases := map[string]struct {
+		found         map[string]bool
+		wantNamespace string
+		notFound      bool
+	}{
+		"app namespace":    {found: map[string]bool{"vela-app": true, "vela-system": true}, wantNamespace: "vela-app"},
+		"x-def namespace":  {found: map[string]bool{"my-vela-system": true, "vela-system": true}, wantNamespace: "my-vela-system"},
+		"system namespace": {found: map[string]bool{"vela-system": true}, wantNamespace: "vela-system"},
+		"cluster scope":    {found: map[string]bool{"": true}, wantNamespace: ""},
+		"not found":        {found: map[string]bool{}, notFound: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				if tt.found[key.Namespace] {
+					obj.SetName(key.Name)
+					obj.SetNamespace(key.Namespace)
+					return nil
+				}
+				return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "traitDefinition"}, key.Name)
+			}}
+			ctx := util.SetXDefinitionNamespaceInCtx(util.SetNamespaceInCtx(context.Background(), "vela-app"), "my-vela-system")
+			ns, err := util.GetDefinitionResolved(ctx, &cli, new(v1beta1.TraitDefinition), "mockdefinition")
+			assert.Equal(t, tt.notFound, apierrors.IsNotFound(err))
+			assert.Equal(t, tt.wantNamespace, ns)
+		})
+	}
+}
+
+func TestIsDummy(t *testing.T) {
+	assert.True(t, util.IsDummyTraitType(util.Dummy))
+	assert.True(t, util.IsDummyTraitType(""))
+	assert.False(t, util.IsDummyTraitType("scaler"))
+
+	assert.True(t, util.IsDummyDefinitionRef(common.DefinitionReference{Name: util.Dummy}))
+	assert.True(t, util.IsDummyDefinitionRef(common.DefinitionReference{}))
+	assert.False(t, util.IsDummyDefinitionRef(common.DefinitionReference{Name: "deployments.apps"}))
+}
+
+func TestCanonicalizeTraitForHash(t *testing.T) {
+	newTrait := func(env []interface{}, ports []interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "core.oam.dev/v1alpha2",
+			"kind":       "Sidecar",
+			"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name":  "sidecar",
+					"env":   env,
+					"ports": ports,
+					"args":  []interface{}{"b", "a"},
+				}},
+			},
+		}}
+	}
+	envA := map[string]interface{}{"name": "A", "value": "1"}
+	envB := map[string]interface{}{"name": "B", "value": "2"}
+	port80 := map[string]interface{}{"containerPort": int64(80)}
+	port443 := map[string]interface{}{"containerPort": int64(443)}
+	trait := newTrait([]interface{}{envA, envB}, []interface{}{port80, port443})
+	reordered := newTrait([]interface{}{envB, envA}, []interface{}{port443, port80})
+
+	assert.NotEqual(t, util.GenTraitName("comp", trait, "sidecar"), util.GenTraitName("comp", reordered, "sidecar"))
+	assert.Equal(t,
+		util.GenTraitName("comp", util.CanonicalizeTraitForHash(trait), "sidecar"),
+		util.GenTraitName("comp", util.CanonicalizeTraitForHash(reordered), "sidecar"))
+
+	// the original trait is not modified and the order of the other lists is kept
+	containers, _, _ := unstructured.NestedSlice(reordered.Object, "spec", "containers")
+	assert.Equal(t, []interface{}{envB, envA}, containers[0].(map[string]interface{})["env"])
+	canonical := util.CanonicalizeTraitForHash(reordered)
+	containers, _, _ = unstructured.NestedSlice(canonical.Object, "spec", "containers")
+	assert.Equal(t, []interface{}{envA, envB}, containers[0].(map[string]interface{})["env"])
+	assert.Equal(t, []interface{}{"b", "a"}, containers[0].(map[string]interface{})["args"])
+	assert.Nil(t, util.CanonicalizeTraitForHash(nil))
+}
+
+func TestGetCapabilityDefinitionAutoUpdateInXDefinitionNamespace(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "v1.0.0", "v1.2.0", "v2.0.0")
+	for i := range revisions {
+		revisions[i].Namespace = "platform"
+		revisions[i].Spec.ComponentDefinition.Spec.Version = strings.TrimPrefix(revisions[i].Name, "worker-v")
+	}
+	var searched []string
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		listOpts := &client.ListOptions{}
+		listOpts.ApplyOptions(opts)
+		searched = append(searched, listOpts.Namespace)
+		if listOpts.Namespace == "platform" {
+			list.(*v1beta1.DefinitionRevisionList).Items = revisions
+		}
+		return nil
+	}, MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		for _, revision := range revisions {
+			if key.Namespace == revision.Namespace && key.Name == revision.Name {
+				revision.DeepCopyInto(obj.(*v1beta1.DefinitionRevision))
+				return nil
+			}
+		}
+		return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "definitionrevisions"}, key.Name)
+	}}
+	ctx := util.SetXDefinitionNamespaceInCtx(util.SetNamespaceInCtx(context.Background(), "app"), "platform")
+	definition := new(v1beta1.ComponentDefinition)
+	err := util.GetCapabilityDefinition(ctx, &cli, definition, "worker@v1", map[string]string{oam.AnnotationAutoUpdate: "true"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", definition.Spec.Version)
+	assert.Equal(t, []string{"app", "platform"}, searched)
+}
+
+func TestGetCapabilityDefinitionWithRevision(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "v1.0.0", "v1.2.0")
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		list.(*v1beta1.DefinitionRevisionList).Items = revisions
+		return nil
+	}, MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		switch o := obj.(type) {
+		case *v1beta1.DefinitionRevision:
+			for _, revision := range revisions {
+				if key.Name == revision.Name {
+					revision.DeepCopyInto(o)
+					return nil
+				}
+			}
+		case *v1beta1.ComponentDefinition:
+			o.SetName(key.Name)
+			return nil
+		}
+		return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "definitionrevisions"}, key.Name)
+	}}
+	ctx := context.Background()
+
+	revisionName, err := util.GetCapabilityDefinitionWithRevision(ctx, &cli, new(v1beta1.ComponentDefinition), "worker", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "", revisionName)
+
+	revisionName, err = util.GetCapabilityDefinitionWithRevision(ctx, &cli, new(v1beta1.ComponentDefinition), "worker@v1.0.0", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.0.0", revisionName)
+
+	revisionName, err = util.GetCapabilityDefinitionWithRevision(ctx, &cli, new(v1beta1.ComponentDefinition), "worker@v1", map[string]string{oam.AnnotationAutoUpdate: "true"})
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.2.0", revisionName)
+
+	_, err = util.GetCapabilityDefinitionWithRevision(ctx, &cli, new(v1beta1.ComponentDefinition), "worker@v3.0.0", nil)
+	assert.Error(t, err)
+}
+func TestGetDefinitionAndRevision(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "v1.0.0", "v1.2.0")
+	revisions[0].Spec.ComponentDefinition.Spec.Version = "1.0.0"
+	revisions[1].Spec.ComponentDefinition.Spec.Version = "1.2.0"
+	latestRevision := &common.Revision{Name: "worker-v3", Revision: 3}
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		list.(*v1beta1.DefinitionRevisionList).Items = revisions
+		return nil
+	}, MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		switch o := obj.(type) {
+		case *v1beta1.DefinitionRevision:
+			for _, revision := range revisions {
+				if key.Name == revision.Name {
+					revision.DeepCopyInto(o)
+					return nil
+				}
+			}
+		case *v1beta1.ComponentDefinition:
+			o.SetName(key.Name)
+			o.Spec.Version = "1.3.0"
+			if key.Name == "worker" {
+				o.Status.LatestRevision = latestRevision
+			}
+			return nil
+		}
+		return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "definitionrevisions"}, key.Name)
+	}}
+	ctx := context.Background()
+
+	testcases := map[string]struct {
+		name         string
+		annotations  map[string]string
+		wantRevision string
+		wantVersion  string
+	}{
+		"pinned":          {name: "worker@v1.0.0", wantRevision: "worker-v1.0.0", wantVersion: "1.0.0"},
+		"auto-update":     {name: "worker@v1", annotations: map[string]string{oam.AnnotationAutoUpdate: "true"}, wantRevision: "worker-v1.2.0", wantVersion: "1.2.0"},
+		"latest floating": {name: "worker", wantRevision: "worker-v3", wantVersion: "1.3.0"},
+		"not revisioned":  {name: "webservice", wantRevision: "", wantVersion: "1.3.0"},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			def := new(v1beta1.ComponentDefinition)
+			revisionName, err := util.GetDefinitionAndRevision(ctx, &cli, def, tt.name, tt.annotations)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRevision, revisionName)
+			assert.Equal(t, tt.wantVersion, def.Spec.Version)
+		})
+	}
+
+	_, err := util.GetDefinitionAndRevision(ctx, &cli, new(v1beta1.ComponentDefinition), "worker@v3.0.0", nil)
+	assert.Error(t, err)
+}
+
+func TestGetCapabilityDefinitionTypeMismatch(t *testing.T) {
+	revision := newComponentDefRevisions("worker", "v1.0.0")[0]
+	revision.Spec.DefinitionType = common.TraitType
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		if o, ok := obj.(*v1beta1.DefinitionRevision); ok && key.Name == revision.Name {
+			revision.DeepCopyInto(o)
+			return nil
+		}
+		return apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "definitionrevisions"}, key.Name)
+	}}
+	ctx := context.Background()
+
+	def := new(v1beta1.ComponentDefinition)
+	err := util.GetCapabilityDefinition(ctx, &cli, def, "worker@v1.0.0", nil)
+	assert.ErrorContains(t, err, `failed to get definition worker@v1.0.0: definition revision worker-v1.0.0 has type "Trait", but "Component" is requested`)
+	assert.Equal(t, "", def.Name)
+
+	revision.Spec.DefinitionType = common.ComponentType
+	assert.NoError(t, util.GetCapabilityDefinition(ctx, &cli, def, "worker@v1.0.0", nil))
+}
+
+func TestCopyLabelsAndAnnotations(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetLabels(map[string]string{"a": "1", "b": "2", "c": "3"})
+	obj.SetAnnotations(map[string]string{"x": "1", "y": "2"})
+
+	labels := util.CopyLabels(obj)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2", "c": "3"}, labels)
+	labels["a"] = "changed"
+	delete(labels, "b")
+	assert.Equal(t, map[string]string{"a": "1", "b": "2", "c": "3"}, obj.GetLabels())
+
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, util.CopyLabels(obj, "a", "c", "missing"))
+
+	annotations := util.CopyAnnotations(obj, "y")
+	assert.Equal(t, map[string]string{"y": "2"}, annotations)
+	annotations["y"] = "changed"
+	assert.Equal(t, map[string]string{"x": "1", "y": "2"}, obj.GetAnnotations())
+
+	empty := util.CopyAnnotations(&unstructured.Unstructured{})
+	assert.NotNil(t, empty)
+	assert.Empty(t, empty)
+}
+
+func TestGetObjectsGivenGVKLabelsAndFields(t *testing.T) {
+	gvk := corev1.SchemeGroupVersion.WithKind("Pod")
+	var listOpts *client.ListOptions
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		listOpts = &client.ListOptions{}
+		listOpts.ApplyOptions(opts)
+		u := list.(*unstructured.UnstructuredList)
+		assert.Equal(t, "v1", u.GetAPIVersion())
+		assert.Equal(t, "Pod", u.GetKind())
+		u.Items = []unstructured.Unstructured{{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "pod-1"}}}}
+		return nil
+	}}
+	ctx := context.Background()
+
+	list, err := util.GetObjectsGivenGVKLabelsAndFields(ctx, &cli, gvk, "default",
+		map[string]string{"app": "web"}, map[string]string{"spec.nodeName": "node-1", "status.phase": "Running"})
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+	assert.Equal(t, "default", listOpts.Namespace)
+	assert.Equal(t, "app=web", listOpts.LabelSelector.String())
+	assert.True(t, listOpts.FieldSelector.Matches(fields.Set{"spec.nodeName": "node-1", "status.phase": "Running"}))
+	assert.False(t, listOpts.FieldSelector.Matches(fields.Set{"spec.nodeName": "node-2", "status.phase": "Running"}))
+
+	list, err = util.GetObjectsGivenGVKAndLabels(ctx, &cli, gvk, "default", map[string]string{"app": "web"})
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+	assert.Nil(t, listOpts.FieldSelector)
+
+	cli.MockList = test.NewMockListFn(errors.New("field label not supported"))
+	_, err = util.GetObjectsGivenGVKLabelsAndFields(ctx, &cli, gvk, "default", nil, map[string]string{"spec.nodeName": "node-1"})
+	assert.ErrorContains(t, err, "field label not supported")
+}
+func TestGetObjectsGivenGVKAndLabelsWithStaleListRead(t *testing.T) {
+	gvk := corev1.SchemeGroupVersion.WithKind("Pod")
+	var listOpts *client.ListOptions
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		listOpts = &client.ListOptions{}
+		listOpts.ApplyOptions(opts)
+		return nil
+	}}
+	ctx := context.Background()
+
+	// the consistent read by default
+	_, err := util.GetObjectsGivenGVKAndLabels(ctx, &cli, gvk, "default", map[string]string{"app": "web"})
+	assert.NoError(t, err)
+	assert.Nil(t, listOpts.Raw)
+
+	_, err = util.GetObjectsGivenGVKAndLabels(ctx, &cli, gvk, "default", map[string]string{"app": "web"}, util.WithStaleListRead())
+	assert.NoError(t, err)
+	assert.Equal(t, "0", listOpts.Raw.ResourceVersion)
+	assert.Equal(t, "default", listOpts.Namespace)
+	assert.Equal(t, "app=web", listOpts.LabelSelector.String())
+	assert.Equal(t, "0", listOpts.AsListOptions().ResourceVersion)
+
+	// the options given by callers are forwarded as well
+	_, err = util.GetObjectsGivenGVKAndLabels(ctx, &cli, gvk, "default", nil, client.Limit(10))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), listOpts.Limit)
+}
+
+func TestIsDefinitionNotFound(t *testing.T) {
+	notFound := apierrors.NewNotFound(v1beta1.SchemeGroupVersion.WithResource("componentdefinitions").GroupResource(), "worker")
+	emptyNamespace := errors.New("an empty namespace may not be set when a resource name is provided")
+	cases := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":                     {err: nil, want: false},
+		"not found":               {err: notFound, want: true},
+		"wrapped not found":       {err: errors.Wrap(notFound, "get definition"), want: true},
+		"fmt wrapped not found":   {err: fmt.Errorf("get definition: %w", notFound), want: true},
+		"empty namespace":         {err: emptyNamespace, want: true},
+		"wrapped empty namespace": {err: errors.Wrap(emptyNamespace, "get definition"), want: true},
+		"generic error":           {err: errors.New("connection refused"), want: false},
+		"forbidden":               {err: apierrors.NewForbidden(schema.GroupResource{}, "worker", errors.New("denied")), want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, util.IsDefinitionNotFound(tc.err))
+		})
+	}
+
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		if key.Namespace == "" {
+			return emptyNamespace
+		}
+		return notFound
+	}}
+	err := util.GetDefinitionFromNamespace(context.Background(), &cli, new(v1beta1.ComponentDefinition), "worker", "default")
+	assert.True(t, util.IsDefinitionNotFound(err))
+}
+
+func TestIsEmptyNamespaceError(t *testing.T) {
+	// the error built by the rest client when getting a namespaced object without namespace
+	restErr := rest.NewRequestWithClient(&url.URL{Scheme: "https", Host: "localhost"}, "", rest.ClientContentConfig{}, nil).
+		Verb("GET").NamespaceIfScoped("", true).Resource("componentdefinitions").Name("worker").Do(context.Background()).Error()
+	assert.Error(t, restErr)
+
+	cases := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":            {err: nil, want: false},
+		"rest client":    {err: restErr, want: true},
+		"wrapped":        {err: errors.Wrap(restErr, "get definition"), want: true},
+		"fmt wrapped":    {err: fmt.Errorf("get definition: %w", restErr), want: true},
+		"bad request":    {err: apierrors.NewBadRequest("an empty namespace may not be set when a resource name is provided"), want: true},
+		"other bad req":  {err: apierrors.NewBadRequest("invalid field"), want: false},
+		"not found":      {err: apierrors.NewNotFound(schema.GroupResource{}, "worker"), want: false},
+		"creation error": {err: errors.New("an empty namespace may not be set during creation"), want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, util.IsEmptyNamespaceError(tc.err))
+		})
+	}
+}
+
+func TestNewDefinitionObject(t *testing.T) {
+	cases := map[common.DefinitionType]client.Object{
+		common.ComponentType:    &v1beta1.ComponentDefinition{},
+		common.TraitType:        &v1beta1.TraitDefinition{},
+		common.PolicyType:       &v1beta1.PolicyDefinition{},
+		common.WorkflowStepType: &v1beta1.WorkflowStepDefinition{},
+	}
+	for definitionType, want := range cases {
+		t.Run(string(definitionType), func(t *testing.T) {
+			obj, err := util.NewDefinitionObject(definitionType)
+			assert.NoError(t, err)
+			assert.Equal(t, want, obj)
+			another, err := util.NewDefinitionObject(definitionType)
+			assert.NoError(t, err)
+			assert.NotSame(t, obj, another)
+		})
+	}
+
+	obj, err := util.NewDefinitionObject("Unknown")
+	assert.ErrorContains(t, err, `invalid definition type "Unknown"`)
+	assert.Nil(t, obj)
+}
+
+func TestExtractDefinitionFromRevision(t *testing.T) {
+	defRev := &v1beta1.DefinitionRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: "def-v1"},
+		Spec: v1beta1.DefinitionRevisionSpec{
+			ComponentDefinition:    v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "worker"}},
+			TraitDefinition:        v1beta1.TraitDefinition{ObjectMeta: metav1.ObjectMeta{Name: "scaler"}},
+			PolicyDefinition:       v1beta1.PolicyDefinition{ObjectMeta: metav1.ObjectMeta{Name: "topology"}},
+			WorkflowStepDefinition: v1beta1.WorkflowStepDefinition{ObjectMeta: metav1.ObjectMeta{Name: "deploy"}},
+		},
+	}
+	cases := map[common.DefinitionType]struct {
+		into client.Object
+		name string
+	}{
+		common.ComponentType:    {into: &v1beta1.ComponentDefinition{}, name: "worker"},
+		common.TraitType:        {into: &v1beta1.TraitDefinition{}, name: "scaler"},
+		common.PolicyType:       {into: &v1beta1.PolicyDefinition{}, name: "topology"},
+		common.WorkflowStepType: {into: &v1beta1.WorkflowStepDefinition{}, name: "deploy"},
+	}
+	for definitionType, tc := range cases {
+		t.Run(string(definitionType), func(t *testing.T) {
+			defRev.Spec.DefinitionType = definitionType
+			assert.NoError(t, util.ExtractDefinitionFromRevision(defRev, tc.into))
+			assert.Equal(t, tc.name, tc.into.GetName())
+			// the extracted definition doesn't share data with the revision
+			tc.into.SetLabels(map[string]string{"foo": "bar"})
+			assert.Empty(t, defRev.Spec.ComponentDefinition.Labels)
+		})
+	}
+
+	defRev.Spec.DefinitionType = common.TraitType
+	def := &v1beta1.ComponentDefinition{}
+	assert.ErrorContains(t, util.ExtractDefinitionFromRevision(defRev, def), `definition revision def-v1 has type "Trait", but "Component" is requested`)
+	assert.Empty(t, def.Name)
+	assert.Error(t, util.ExtractDefinitionFromRevision(defRev, &v1beta1.Application{}))
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	trait := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "labels": map[string]interface{}{"z": "1", "a": "<2>"}},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"big":      int64(9007199254740993),
+			"ratio":    0.5,
+			"ports":    []interface{}{map[string]interface{}{"port": int64(80), "name": "http"}},
+		},
+	}}
+	const golden = `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"labels":{"a":"<2>","z":"1"},"name":"web"},` +
+		`"spec":{"big":9007199254740993,"ports":[{"name":"http","port":80}],"ratio":0.5,"replicas":3}}`
+
+	data, err := util.CanonicalJSON(trait.Object)
+	assert.NoError(t, err)
+	assert.Equal(t, golden, string(data))
+
+	// the struct fields are sorted as well
+	data, err = util.CanonicalJSON(struct {
+		B string `json:"b"`
+		A int    `json:"a"`
+	}{B: "x", A: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1,"b":"x"}`, string(data))
+
+	_, err = util.CanonicalJSON(func() {})
+	assert.Error(t, err)
+
+	hash, err := util.ComputeHashStable(trait)
+	assert.NoError(t, err)
+	assert.Equal(t, "585fdc6f48", hash)
+	same, err := util.ComputeHashStable(trait.DeepCopy())
+	assert.NoError(t, err)
+	assert.Equal(t, hash, same)
+	changed := trait.DeepCopy()
+	assert.NoError(t, unstructured.SetNestedField(changed.Object, int64(4), "spec", "replicas"))
+	other, err := util.ComputeHashStable(changed)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestUpdateDefinitionWithRetry(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1beta1.AddToScheme(scheme))
+	var conflicts, updates int
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1beta1.TraitDefinition{ObjectMeta: metav1.ObjectMeta{Name: "scaler", Namespace: "vela-system"}},
+	).WithInterceptorFuncs(interceptor.Funcs{Update: func(ctx context.Context, cli client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+		updates++
+		if conflicts > 0 {
+			conflicts--
+			return apierrors.NewConflict(schema.GroupResource{Group: "core.oam.dev", Resource: "traitdefinitions"}, obj.GetName(), errors.New("modified"))
+		}
+		return cli.Update(ctx, obj, opts...)
+	}}).Build()
+	ctx := context.Background()
+
+	// conflict then success
+	conflicts = 1
+	def := &v1beta1.TraitDefinition{ObjectMeta: metav1.ObjectMeta{Name: "scaler", Namespace: "vela-system"}}
+	assert.NoError(t, util.UpdateDefinitionWithRetry(ctx, cli, def, func() {
+		def.Spec.PodDisruptive = true
+	}))
+	assert.Equal(t, 2, updates)
+	got := &v1beta1.TraitDefinition{}
+	assert.NoError(t, cli.Get(ctx, client.ObjectKey{Name: "scaler", Namespace: "vela-system"}, got))
+	assert.True(t, got.Spec.PodDisruptive)
+
+	// keep conflicting
+	conflicts = 100
+	err := util.UpdateDefinitionWithRetry(ctx, cli, def, func() {})
+	assert.ErrorContains(t, err, "cannot update TraitDefinition scaler")
+
+	err = util.UpdateDefinitionWithRetry(ctx, cli, &v1beta1.ComponentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "vela-system"}}, func() {})
+	assert.ErrorContains(t, err, "cannot update ComponentDefinition worker")
+
+	assert.Error(t, util.UpdateDefinitionWithRetry(ctx, cli, &v1beta1.Application{}, func() {}))
+}
+
+func TestParseDefinitionReference(t *testing.T) {
+	cases := map[string]struct {
+		name    string
+		defName string
+		version string
+		pinned  bool
+		hasErr  bool
+	}{
+		"plain name":  {name: "worker", defName: "worker"},
+		"exact":       {name: "worker@v1.3.1", defName: "worker", version: "v1.3.1", pinned: true},
+		"constraint":  {name: "worker@^1.3", defName: "worker", version: "^1.3", pinned: true},
+		"channel":     {name: "worker@stable", defName: "worker", version: "stable", pinned: true},
+		"no version":  {name: "worker@", pinned: true, hasErr: true},
+		"no name":     {name: "@v1.3.1", pinned: true, hasErr: true},
+		"double @":    {name: "worker@v1@v2", pinned: true, hasErr: true},
+		"bad name":    {name: "Worker_", hasErr: true},
+		"bad version": {name: "worker@v1.x!", pinned: true, hasErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.pinned, util.IsPinnedDefinitionReference(tc.name))
+			defName, version, pinned, err := util.ParseDefinitionReference(tc.name)
+			if tc.hasErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.defName, defName)
+			assert.Equal(t, tc.version, version)
+			assert.Equal(t, tc.pinned, pinned)
+		})
+	}
+}
+
+func TestIsNamespacedGVK(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{corev1.SchemeGroupVersion, appsv1.SchemeGroupVersion})
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+	testcases := map[string]struct {
+		gvk        schema.GroupVersionKind
+		namespaced bool
+		noMatch    bool
+	}{
+		"namespaced":     {gvk: appsv1.SchemeGroupVersion.WithKind("Deployment"), namespaced: true},
+		"cluster scoped": {gvk: corev1.SchemeGroupVersion.WithKind("Namespace"), namespaced: false},
+		"no version":     {gvk: schema.GroupVersionKind{Group: "apps", Kind: "Deployment"}, namespaced: true},
+		"not found":      {gvk: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"}, noMatch: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			namespaced, err := util.IsNamespacedGVK(mapper, tt.gvk)
+			if tt.noMatch {
+				assert.True(t, meta.IsNoMatchError(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.namespaced, namespaced)
+		})
+	}
+}
+
+func TestGetObjectsGivenGVKAndLabelsAllNamespaces(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+	newConfigMap := func(name, ns string, labels map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels}}
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newConfigMap("a", "ns-1", map[string]string{oam.LabelAppName: "app"}),
+		newConfigMap("b", "ns-2", map[string]string{oam.LabelAppName: "app"}),
+		newConfigMap("c", "ns-2", map[string]string{oam.LabelAppName: "other"}),
+	).Build()
+	ctx := context.Background()
+	gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+
+	list, err := util.GetObjectsGivenGVKAndLabelsAllNamespaces(ctx, cli, gvk, map[string]string{oam.LabelAppName: "app"})
+	assert.NoError(t, err)
+	var keys []string
+	for _, item := range list.Items {
+		keys = append(keys, item.GetNamespace()+"/"+item.GetName())
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"ns-1/a", "ns-2/b"}, keys)
+
+	var listOpts *client.ListOptions
+	mockCli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		listOpts = &client.ListOptions{}
+		listOpts.ApplyOptions(opts)
+		return nil
+	}}
+	_, err = util.GetObjectsGivenGVKAndLabelsAllNamespaces(ctx, &mockCli, gvk, map[string]string{oam.LabelAppName: "app"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", listOpts.Namespace)
+	assert.Equal(t, oam.LabelAppName+"=app", listOpts.LabelSelector.String())
+}
+
+func TestSanitizeOAMMetadata(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetLabels(map[string]string{
+		oam.LabelAppName:                 "app",
+		oam.LabelAppRevisionHash:         "abc",
+		oam.LabelAppComponent:            "comp",
+		oam.WorkloadTypeLabel:            "webservice",
+		oam.TraitTypeLabel:               "scaler",
+		oam.LabelControllerName:          "vela",
+		"app.kubernetes.io/name":         "web",
+		"team":                           "dev",
+		oam.LabelComponentDefinitionName: "worker",
+	})
+	obj.SetAnnotations(map[string]string{
+		oam.AnnotationLastAppliedConfig:     "{}",
+		oam.AnnotationSkipResume:            "true",
+		oam.AnnotationKubeVelaVersion:       "v1.9.0",
+		"kubectl.kubernetes.io/restartedAt": "now",
+	})
+	util.SanitizeOAMMetadata(obj)
+	assert.Equal(t, map[string]string{
+		"app.kubernetes.io/name":         "web",
+		"team":                           "dev",
+		oam.LabelComponentDefinitionName: "worker",
+	}, obj.GetLabels())
+	assert.Equal(t, map[string]string{
+		oam.AnnotationKubeVelaVersion:       "v1.9.0",
+		"kubectl.kubernetes.io/restartedAt": "now",
+	}, obj.GetAnnotations())
+
+	// no-op on the object without labels or annotations
+	empty := &unstructured.Unstructured{}
+	util.SanitizeOAMMetadata(empty)
+	assert.Empty(t, empty.GetLabels())
+	assert.Empty(t, empty.GetAnnotations())
+
+	util.RemoveLabelsByPrefix(obj, []string{"app.kubernetes.io/", "team"})
+	assert.Equal(t, map[string]string{oam.LabelComponentDefinitionName: "worker"}, obj.GetLabels())
+}
+
+func TestHashObject(t *testing.T) {
+	trait := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"spec":       map[string]interface{}{"replicas": int64(3)},
+	}}
+	hasher := fnv.New32a()
+	util.DeepHashObject(hasher, *trait)
+	manual := rand.SafeEncodeString(fmt.Sprint(hasher.Sum32()))
+
+	assert.Equal(t, manual, util.HashObject(*trait))
+	assert.Equal(t, manual, util.ComputeHash(trait))
+	assert.Equal(t, util.ComputeHashWithCollisionCount(trait, nil), util.ComputeHash(trait))
+	assert.NotEqual(t, util.HashObject(*trait), util.HashObject(map[string]interface{}{"replicas": int64(3)}))
+}
+func TestComputeHashMulti(t *testing.T) {
+	workload := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Deployment", "spec": map[string]interface{}{"replicas": int64(1)}}}
+	scaler := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Scaler"}}
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Gateway"}}
+
+	hash := util.ComputeHashMulti(*workload, *scaler, *gateway)
+	assert.Equal(t, hash, util.ComputeHashMulti(*workload.DeepCopy(), *scaler.DeepCopy(), *gateway.DeepCopy()))
+	assert.Empty(t, validation.IsDNS1123Label(hash))
+
+	// the order of the objects matters
+	assert.NotEqual(t, hash, util.ComputeHashMulti(*workload, *gateway, *scaler))
+	assert.NotEqual(t, hash, util.ComputeHashMulti(*workload, *scaler))
+
+	// any change of the objects changes the hash
+	changed := workload.DeepCopy()
+	changed.Object["spec"] = map[string]interface{}{"replicas": int64(2)}
+	assert.NotEqual(t, hash, util.ComputeHashMulti(*changed, *scaler, *gateway))
+
+	// the objects are not concatenated ambiguously
+	assert.NotEqual(t, util.ComputeHashMulti("ab", "c"), util.ComputeHashMulti("a", "bc"))
+
+	assert.Equal(t, util.HashObject(*workload), util.ComputeHashMulti(*workload))
+	assert.Equal(t, util.ComputeHashMulti(), util.ComputeHashMulti())
+}
+
+func TestObjectsDiffer(t *testing.T) {
+	desired := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+		"spec":       map[string]interface{}{"replicas": int64(3)},
+	}}
+	newLive := func() *unstructured.Unstructured {
+		live := desired.DeepCopy()
+		live.SetResourceVersion("12")
+		live.SetUID("uid")
+		live.Object["status"] = map[string]interface{}{"readyReplicas": float64(3)}
+		// the numbers decoded from the server are float64
+		live.Object["spec"] = map[string]interface{}{"replicas": float64(3)}
+		return live
+	}
+
+	differ, paths, err := util.ObjectsDiffer(desired, newLive(), util.DefaultDriftIgnorePaths)
+	assert.NoError(t, err)
+	assert.False(t, differ)
+	assert.Empty(t, paths)
+
+	live := newLive()
+	assert.NoError(t, unstructured.SetNestedField(live.Object, float64(1), "spec", "replicas"))
+	live.SetLabels(map[string]string{"foo": "bar"})
+	differ, paths, err = util.ObjectsDiffer(desired, live, util.DefaultDriftIgnorePaths)
+	assert.NoError(t, err)
+	assert.True(t, differ)
+	assert.Equal(t, []string{"metadata", "spec"}, paths)
+
+	live = newLive()
+	live.Object["data"] = map[string]interface{}{"extra": "x"}
+	differ, paths, err = util.ObjectsDiffer(desired, live, util.DefaultDriftIgnorePaths)
+	assert.NoError(t, err)
+	assert.True(t, differ)
+	assert.Equal(t, []string{"data"}, paths)
+
+	// the difference in the ignored fields
+	differ, paths, err = util.ObjectsDiffer(desired, newLive(), []string{"metadata.resourceVersion", "metadata.uid", "status"})
+	assert.NoError(t, err)
+	assert.False(t, differ)
+	assert.Empty(t, paths)
+	differ, paths, err = util.ObjectsDiffer(desired, newLive(), nil)
+	assert.NoError(t, err)
+	assert.True(t, differ)
+	assert.Equal(t, []string{"metadata", "status"}, paths)
+}
+
+func TestContextAwareNamespaceAccessor(t *testing.T) {
+	withNs := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "origin"}}
+	withoutNs := &corev1.ConfigMap{}
+
+	accessor := util.NewContextAwareNamespaceAccessor(context.Background(), "app")
+	assert.Equal(t, "app", accessor.Namespace())
+	assert.Equal(t, "origin", accessor.For(withNs))
+	assert.Equal(t, "app", accessor.For(withoutNs))
+
+	ctx := util.SetOverrideNamespaceInCtx(context.Background(), "override")
+	assert.Equal(t, "override", util.GetOverrideNamespaceWithCtx(ctx))
+	accessor = util.NewContextAwareNamespaceAccessor(ctx, "app")
+	assert.Equal(t, "override", accessor.Namespace())
+	assert.Equal(t, "override", accessor.For(withNs))
+	assert.Equal(t, "override", accessor.For(withoutNs))
+
+	// the empty override in context is ignored
+	accessor = util.NewContextAwareNamespaceAccessor(util.SetOverrideNamespaceInCtx(context.Background(), ""), "app")
+	assert.Equal(t, "app", accessor.Namespace())
+	assert.Equal(t, "origin", accessor.For(withNs))
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	testcases := map[string]struct {
+		version string
+		want    string
+		hasErr  bool
+	}{
+		"with v":         {version: "v1.2.3", want: "1.2.3"},
+		"without v":      {version: "1.2.3", want: "1.2.3"},
+		"build metadata": {version: "1.2.3+build", want: "1.2.3+build"},
+		"prerelease":     {version: "v1.2.3-alpha.1", want: "1.2.3-alpha.1"},
+		"partial":        {version: "v1.2", want: "1.2.0"},
+		"empty":          {version: "", hasErr: true},
+		"only v":         {version: "v", hasErr: true},
+		"double v":       {version: "vv1.2.3", hasErr: true},
+		"invalid":        {version: "1.x.y", hasErr: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			got, err := util.NormalizeVersion(tt.version)
+			if tt.hasErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetLatestDefinitionRevisionNameMalformedRevision(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "v1.0.0", "vbad", "v1.2.0", "latest")
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		list.(*v1beta1.DefinitionRevisionList).Items = revisions
+		return nil
+	}}
+	var name string
+	var err error
+	assert.NotPanics(t, func() {
+		name, err = util.GetLatestDefinitionRevisionName(context.Background(), &cli, "worker", "worker-v1", common.ComponentType)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.2.0", name)
+
+	revisions = newComponentDefRevisions("worker", "vbad")
+	assert.NotPanics(t, func() {
+		_, err = util.GetLatestDefinitionRevisionName(context.Background(), &cli, "worker", "worker-v1", common.ComponentType)
+	})
+	assert.Error(t, err)
+}
+
+func TestGetLatestDefinitionRevisionNameConstraint(t *testing.T) {
+	revisions := newComponentDefRevisions("worker", "v1.2.0", "v1.2.3-beta.1", "v1.3.0-rc.1")
+	var listCount int
+	cli := test.MockClient{MockList: func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		listCount++
+		list.(*v1beta1.DefinitionRevisionList).Items = revisions
+		return nil
+	}}
+	ctx := util.SetNamespaceInCtx(context.Background(), "vela-app")
+
+	// the partial version in revision name matches the prereleases like the prefix match
+	name, err := util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", "worker-v1.2", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.2.3-beta.1", name)
+	name, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", "worker-v1", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.3.0-rc.1", name)
+
+	// the semver constraint only matches the prereleases if it has a prerelease itself
+	name, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", "^1.2", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.2.0", name)
+	name, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", ">=1.3.0-0", common.ComponentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "worker-v1.3.0-rc.1", name)
+
+	// the invalid constraint is reported directly without searching the other namespaces
+	listCount = 0
+	_, err = util.GetLatestDefinitionRevisionName(ctx, &cli, "worker", "1.x.y!", common.ComponentType)
+	assert.ErrorContains(t, err, `invalid version constraint "1.x.y!" for definition worker`)
+	assert.Equal(t, 1, listCount)
+}
+
+func TestCanonicalizeDefinitionReference(t *testing.T) {
+	testcases := map[string]struct {
+		ref    common.DefinitionReference
+		want   common.DefinitionReference
+		hasErr bool
+	}{
+		"plain":            {ref: common.DefinitionReference{Name: "deployments.apps"}, want: common.DefinitionReference{Name: "deployments.apps"}},
+		"version in name":  {ref: common.DefinitionReference{Name: "deployments.apps/v1"}, want: common.DefinitionReference{Name: "deployments.apps", Version: "v1"}},
+		"mixed case":       {ref: common.DefinitionReference{Name: " Deployments.Apps ", Version: "V1"}, want: common.DefinitionReference{Name: "deployments.apps", Version: "v1"}},
+		"same versions":    {ref: common.DefinitionReference{Name: "deployments.apps/v1", Version: "v1"}, want: common.DefinitionReference{Name: "deployments.apps", Version: "v1"}},
+		"core":             {ref: common.DefinitionReference{Name: "services/v1"}, want: common.DefinitionReference{Name: "services", Version: "v1"}},
+		"dummy":            {ref: common.DefinitionReference{Name: util.Dummy}, want: common.DefinitionReference{Name: util.Dummy}},
+		"empty":            {ref: common.DefinitionReference{}, want: common.DefinitionReference{}},
+		"conflict version": {ref: common.DefinitionReference{Name: "deployments.apps/v1", Version: "v2"}, hasErr: true},
+		"no name":          {ref: common.DefinitionReference{Name: "/v1"}, hasErr: true},
+		"multiple slashes": {ref: common.DefinitionReference{Name: "apps/deployments/v1"}, hasErr: true},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			got, err := util.CanonicalizeDefinitionReference(tt.ref)
+			if tt.hasErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{corev1.SchemeGroupVersion, appsv1.SchemeGroupVersion})
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Service"), meta.RESTScopeNamespace)
+	for _, ref := range []common.DefinitionReference{
+		{Name: "deployments.apps"},
+		{Name: "deployments.apps/v1"},
+		{Name: "Deployments.Apps", Version: "v1"},
+	} {
+		got, err := util.CanonicalizeDefinitionReferenceWithMapper(mapper, ref)
+		assert.NoError(t, err)
+		assert.Equal(t, common.DefinitionReference{Name: "deployments.apps", Version: "v1"}, got)
+	}
+	got, err := util.CanonicalizeDefinitionReferenceWithMapper(mapper, common.DefinitionReference{Name: "services"})
+	assert.NoError(t, err)
+	assert.Equal(t, common.DefinitionReference{Name: "services", Version: "v1"}, got)
+	_, err = util.CanonicalizeDefinitionReferenceWithMapper(mapper, common.DefinitionReference{Name: "foos.example.com"})
+	assert.True(t, meta.IsNoMatchError(err))
+}
+
+func TestApplyLabelsPlan(t *testing.T) {
+	unchanged := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"app": "web", "team": "dev"}}}
+	changed := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{"app": "old", "other": "x"}}}
+	empty := &unstructured.Unstructured{}
+	labels := map[string]string{"app": "web", "team": "dev"}
+
+	plan := util.ApplyLabelsPlan([]metav1.Object{unchanged, changed, empty}, labels)
+	assert.True(t, plan.HasChanges())
+	assert.Len(t, plan.Items, 3)
+	assert.Empty(t, plan.Items[0].Changes)
+	assert.Equal(t, []util.LabelChange{
+		{Key: "app", Existed: true, Before: "old", After: "web"},
+		{Key: "team", Existed: false, After: "dev"},
+	}, plan.Items[1].Changes)
+	assert.Equal(t, []util.LabelChange{
+		{Key: "app", After: "web"},
+		{Key: "team", After: "dev"},
+	}, plan.Items[2].Changes)
+	// the objects are not mutated by the plan
+	assert.Equal(t, map[string]string{"app": "old", "other": "x"}, changed.GetLabels())
+	assert.Empty(t, empty.GetLabels())
+
+	plan.Apply()
+	assert.Equal(t, map[string]string{"app": "web", "team": "dev"}, unchanged.GetLabels())
+	assert.Equal(t, map[string]string{"app": "web", "team": "dev", "other": "x"}, changed.GetLabels())
+	assert.Equal(t, labels, empty.GetLabels())
+
+	plan = util.ApplyLabelsPlan([]metav1.Object{unchanged, changed, empty}, labels)
+	assert.False(t, plan.HasChanges())
+}
+
+func TestMergeConditionsPreservingTransition(t *testing.T) {
+	before := metav1.NewTime(time.Now().Add(-time.Hour))
+	now := metav1.Now()
+	existing := []condition.Condition{
+		{Type: condition.TypeReady, Status: corev1.ConditionTrue, Reason: condition.ReasonAvailable, LastTransitionTime: before},
+		{Type: condition.TypeSynced, Status: corev1.ConditionTrue, Reason: condition.ReasonReconcileSuccess, LastTransitionTime: before},
+	}
+	incoming := []condition.Condition{
+		// message-only change
+		{Type: condition.TypeReady, Status: corev1.ConditionTrue, Reason: condition.ReasonAvailable, Message: "ready", LastTransitionTime: now},
+		// status change
+		{Type: condition.TypeSynced, Status: corev1.ConditionFalse, Reason: condition.ReasonReconcileError, LastTransitionTime: now},
+		// new condition
+		{Type: "Healthy", Status: corev1.ConditionTrue, LastTransitionTime: now},
+	}
+	merged := util.MergeConditionsPreservingTransition(existing, incoming)
+	assert.Len(t, merged, 3)
+	assert.Equal(t, before, merged[0].LastTransitionTime)
+	assert.Equal(t, "ready", merged[0].Message)
+	assert.Equal(t, now, merged[1].LastTransitionTime)
+	assert.Equal(t, corev1.ConditionFalse, merged[1].Status)
+	assert.Equal(t, now, merged[2].LastTransitionTime)
+	// the incoming conditions are not modified
+	assert.Equal(t, now, incoming[0].LastTransitionTime)
+
+	assert.Nil(t, util.MergeConditionsPreservingTransition(existing, nil))
+	assert.Equal(t, incoming, util.MergeConditionsPreservingTransition(nil, incoming))
+}
+
+func TestBuildStableName(t *testing.T) {
+	assert.Equal(t, "comp-scaler-hash", util.BuildStableName("hash", "comp", "scaler"))
+
+	longComp := strings.Repeat("a", 300)
+	name := util.BuildStableName("hash1", longComp, "scaler")
+	assert.Len(t, name, 253)
+	assert.True(t, strings.HasSuffix(name, "-scaler-hash1"))
+	assert.Empty(t, validation.IsDNS1123Subdomain(name))
+	assert.Equal(t, name, util.BuildStableName("hash1", longComp, "scaler"))
+
+	// both parts are too long, the budget is shared
+	name = util.BuildStableName("hash1", longComp, strings.Repeat("b", 300))
+	assert.LessOrEqual(t, len(name), 253)
+	assert.Equal(t, strings.Repeat("a", 123)+"-"+strings.Repeat("b", 123)+"-hash1", name)
+
+	// the trailing separators of the truncated parts are trimmed
+	name = util.BuildStableName("hash1", strings.Repeat("a", 238)+"--"+strings.Repeat("c", 50), "scaler")
+	assert.Empty(t, validation.IsDNS1123Subdomain(name))
+	assert.Equal(t, strings.Repeat("a", 238)+"-scaler-hash1", name)
+
+	trait := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Scaler"}}
+	traitName := util.GenTraitName(longComp, trait, "scaler")
+	assert.Len(t, traitName, 253)
+	assert.True(t, strings.HasSuffix(traitName, "-scaler-"+util.ComputeHash(trait)))
+	assert.Equal(t, "comp-scaler-"+util.ComputeHash(trait), util.GenTraitName("comp", trait, "scaler"))
+}
+
+func TestGetObjectGivenGVKAndNameTyped(t *testing.T) {
+	ctx := context.Background()
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	notFound := apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, "missing")
+	cli := test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		switch key.Name {
+		case "missing":
+			return notFound
+		case "broken":
+			return fmt.Errorf("connection refused")
+		default:
+			obj.SetName(key.Name)
+			return nil
+		}
+	}}
+
+	obj, err := util.GetObjectGivenGVKAndNameTyped(ctx, &cli, gvk, "default", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, "web", obj.GetName())
+	assert.Equal(t, gvk, obj.GroupVersionKind())
+
+	_, err = util.GetObjectGivenGVKAndNameTyped(ctx, &cli, gvk, "default", "missing")
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, notFound, err)
+
+	_, err = util.GetObjectGivenGVKAndNameTyped(ctx, &cli, gvk, "default", "broken")
+	assert.ErrorContains(t, err, "failed to get obj broken")
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestRawExtension2Object(t *testing.T) {
+	cd := &v1beta1.ComponentDefinition{}
+	raw := runtime.RawExtension{Raw: []byte(`{"apiVersion":"core.oam.dev/v1beta1","kind":"ComponentDefinition","metadata":{"name":"webservice","namespace":"vela-system"},"spec":{"workload":{"definition":{"apiVersion":"apps/v1","kind":"Deployment"}}}}`)}
+	assert.NoError(t, util.RawExtension2Object(raw, cd))
+	assert.Equal(t, "webservice", cd.Name)
+	assert.Equal(t, "vela-system", cd.Namespace)
+	assert.Equal(t, "Deployment", cd.Spec.Workload.Definition.Kind)
+
+	app := &v1beta1.Application{}
+	raw = runtime.RawExtension{Object: &v1beta1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec:       v1beta1.ApplicationSpec{Components: []common.ApplicationComponent{{Name: "comp", Type: "webservice"}}},
+	}}
+	assert.NoError(t, util.RawExtension2Object(raw, app))
+	assert.Equal(t, "app", app.Name)
+	assert.Equal(t, "webservice", app.Spec.Components[0].Type)
+
+	untouched := &v1beta1.Application{ObjectMeta: metav1.ObjectMeta{Name: "untouched"}}
+	assert.NoError(t, util.RawExtension2Object(runtime.RawExtension{}, untouched))
+	assert.Equal(t, "untouched", untouched.Name)
+
+	assert.Error(t, util.RawExtension2Object(runtime.RawExtension{Raw: []byte(`[1,2]`)}, app))
+}
+
+func TestObject2RawExtensionSafe(t *testing.T) {
+	raw, err := util.Object2RawExtensionSafe(map[string]interface{}{"a": "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, util.Object2RawExtension(map[string]interface{}{"a": "b"}), raw)
+
+	raw, err = util.Object2RawExtensionSafe(map[string]interface{}{"ch": make(chan int)})
+	assert.Error(t, err)
+	assert.Nil(t, raw)
+	assert.Panics(t, func() { util.Object2RawExtension(make(chan int)) })
+}
+
+func TestParseTraitName(t *testing.T) {
+	trait := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Ingress"}}
+	hash := util.ComputeHash(trait)
+	testcases := map[string]struct {
+		name          string
+		componentHint string
+		wantComponent string
+		wantTraitType string
+		wantHash      string
+		wantOK        bool
+	}{
+		"simple name without hint": {
+			name: util.GenTraitName("web", trait, "gateway"), wantComponent: "web", wantTraitType: "gateway", wantHash: hash, wantOK: true,
+		},
+		"dashes in component name with hint": {
+			name: util.GenTraitName("my-web-app", trait, "gateway"), componentHint: "my-web-app",
+			wantComponent: "my-web-app", wantTraitType: "gateway", wantHash: hash, wantOK: true,
+		},
+		"dashes in both names with hint": {
+			name: util.GenTraitName("my-web", trait, "k8s-update-strategy"), componentHint: "my-web",
+			wantComponent: "my-web", wantTraitType: "k8s-update-strategy", wantHash: hash, wantOK: true,
+		},
+		"ambiguous name without hint": {
+			name: util.GenTraitName("my-web", trait, "gateway"),
+		},
+		"dummy trait type": {
+			name: util.GenTraitName("web", trait, ""), componentHint: "web",
+			wantComponent: "web", wantTraitType: util.TraitPrefixKey, wantHash: hash, wantOK: true,
+		},
+		"mismatched hint": {
+			name: util.GenTraitName("web", trait, "gateway"), componentHint: "api",
+		},
+		"no trait type": {
+			name: "web-" + hash, componentHint: "web",
+		},
+		"no separator": {
+			name: "web",
+		},
+		"trailing separator": {
+			name: "web-gateway-",
+		},
+	}
+	for name, tt := range testcases {
+		t.Run(name, func(t *testing.T) {
+			component, traitType, h, ok := util.ParseTraitName(tt.name, tt.componentHint)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantComponent, component)
+			assert.Equal(t, tt.wantTraitType, traitType)
+			assert.Equal(t, tt.wantHash, h)
+		})
+	}
+}
+
+func TestResourceKey(t *testing.T) {
+	deploy := &unstructured.Unstructured{}
+	deploy.SetAPIVersion("apps/v1")
+	deploy.SetKind("Deployment")
+	deploy.SetNamespace("default")
+	deploy.SetName("web")
+	assert.Equal(t, "apps/v1/Deployment/default/web", util.ResourceKey(deploy))
+	assert.Equal(t, util.ResourceKey(deploy), util.ResourceKeyFromGVK(appsv1.SchemeGroupVersion.WithKind("Deployment"), "default", "web"))
+
+	typed := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+	}
+	assert.Equal(t, util.ResourceKey(deploy), util.ResourceKey(typed))
+
+	keys := map[string]struct{}{}
+	for _, key := range []string{
+		util.ResourceKey(deploy),
+		util.ResourceKeyFromGVK(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "default", "web"),
+		util.ResourceKeyFromGVK(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, "default", "web"),
+		util.ResourceKeyFromGVK(schema.GroupVersionKind{Group: "apps", Version: "v1beta1", Kind: "Deployment"}, "default", "web"),
+		util.ResourceKeyFromGVK(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "prod", "web"),
+		util.ResourceKeyFromGVK(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "default", "api"),
+		util.ResourceKeyFromGVK(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "", "default-web"),
+	} {
+		keys[key] = struct{}{}
+	}
+	assert.Len(t, keys, 7)
+}
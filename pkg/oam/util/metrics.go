@@ -0,0 +1,68 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	velametrics "github.com/kubevela/pkg/monitor/metrics"
+)
+
+var (
+	// DefinitionLookupCounter report the number of definition lookups
+	DefinitionLookupCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "definition_lookup_total",
+		Help: "definition lookup times.",
+	})
+
+	// DefinitionSystemNamespaceFallbackCounter report the number of definition lookups falling back to the system namespace
+	DefinitionSystemNamespaceFallbackCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "definition_lookup_system_namespace_fallback_total",
+		Help: "definition lookup fallback to system namespace times.",
+	})
+
+	// DefinitionClusterScopeFallbackCounter report the number of definition lookups falling back to cluster scope for compatibility
+	DefinitionClusterScopeFallbackCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "definition_lookup_cluster_scope_fallback_total",
+		Help: "definition lookup fallback to cluster scope times.",
+	})
+
+	// DefinitionLookupDurationHistogram report the time cost of definition lookups
+	DefinitionLookupDurationHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:        "definition_lookup_time_seconds",
+		Help:        "definition lookup duration distributions.",
+		Buckets:     velametrics.FineGrainedBuckets,
+		ConstLabels: prometheus.Labels{},
+	})
+)
+
+var definitionCollectorGroup = []prometheus.Collector{
+	DefinitionLookupCounter,
+	DefinitionSystemNamespaceFallbackCounter,
+	DefinitionClusterScopeFallbackCounter,
+	DefinitionLookupDurationHistogram,
+}
+
+func init() {
+	for _, collector := range definitionCollectorGroup {
+		if err := metrics.Registry.Register(collector); err != nil {
+			klog.Error(err)
+		}
+	}
+}
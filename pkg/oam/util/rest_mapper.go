@@ -0,0 +1,102 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CachingRESTMapper is a RESTMapper which memorizes the results of RESTMapping and KindsFor of the delegated mapper.
+// It can be passed to GetDefinitionName, GetGVKFromDefinition and ConvertWorkloadGVK2Definition to avoid the
+// discovery refreshes triggered by some mapper implementations. The failed lookups are not cached, and the
+// delegated mapper will be reset on NoResourceMatchError if it is resettable, so newly installed CRDs are picked up.
+type CachingRESTMapper struct {
+	meta.RESTMapper
+
+	mu       sync.RWMutex
+	mappings map[string]*meta.RESTMapping
+	kinds    map[schema.GroupVersionResource][]schema.GroupVersionKind
+}
+
+var _ meta.ResettableRESTMapper = &CachingRESTMapper{}
+
+// NewCachingRESTMapper create a CachingRESTMapper which delegates the lookups to the given mapper
+func NewCachingRESTMapper(mapper meta.RESTMapper) *CachingRESTMapper {
+	return &CachingRESTMapper{
+		RESTMapper: mapper,
+		mappings:   map[string]*meta.RESTMapping{},
+		kinds:      map[schema.GroupVersionResource][]schema.GroupVersionKind{},
+	}
+}
+
+// RESTMapping returns the cached RESTMapping for the GroupKind and versions, or looks it up from the delegated mapper
+func (m *CachingRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	key := gk.String() + "/" + strings.Join(versions, ",")
+	m.mu.RLock()
+	mapping, ok := m.mappings[key]
+	m.mu.RUnlock()
+	if ok {
+		return mapping, nil
+	}
+	mapping, err := m.RESTMapper.RESTMapping(gk, versions...)
+	if err != nil {
+		m.resetOnNoMatch(err)
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mappings[key] = mapping
+	return mapping, nil
+}
+
+// KindsFor returns the cached kinds for the GroupVersionResource, or looks them up from the delegated mapper
+func (m *CachingRESTMapper) KindsFor(gvr schema.GroupVersionResource) ([]schema.GroupVersionKind, error) {
+	m.mu.RLock()
+	kinds, ok := m.kinds[gvr]
+	m.mu.RUnlock()
+	if ok {
+		return kinds, nil
+	}
+	kinds, err := m.RESTMapper.KindsFor(gvr)
+	if err != nil {
+		m.resetOnNoMatch(err)
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kinds[gvr] = kinds
+	return kinds, nil
+}
+
+// Reset removes all the cached results and resets the delegated mapper if it is resettable
+func (m *CachingRESTMapper) Reset() {
+	m.mu.Lock()
+	m.mappings = map[string]*meta.RESTMapping{}
+	m.kinds = map[schema.GroupVersionResource][]schema.GroupVersionKind{}
+	m.mu.Unlock()
+	meta.MaybeResetRESTMapper(m.RESTMapper)
+}
+
+func (m *CachingRESTMapper) resetOnNoMatch(err error) {
+	if meta.IsNoMatchError(err) {
+		meta.MaybeResetRESTMapper(m.RESTMapper)
+	}
+}
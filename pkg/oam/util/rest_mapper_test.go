@@ -0,0 +1,93 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+// countingRESTMapper counts the lookups and resets of the underlying mapper
+type countingRESTMapper struct {
+	meta.RESTMapper
+	mappingCalls int
+	kindsCalls   int
+	resets       int
+}
+
+func (m *countingRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	m.mappingCalls++
+	return m.RESTMapper.RESTMapping(gk, versions...)
+}
+
+func (m *countingRESTMapper) KindsFor(gvr schema.GroupVersionResource) ([]schema.GroupVersionKind, error) {
+	m.kindsCalls++
+	return m.RESTMapper.KindsFor(gvr)
+}
+
+func (m *countingRESTMapper) Reset() {
+	m.resets++
+}
+
+func TestCachingRESTMapper(t *testing.T) {
+	deployGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	defaultMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{deployGVK.GroupVersion()})
+	defaultMapper.Add(deployGVK, meta.RESTScopeNamespace)
+	underlying := &countingRESTMapper{RESTMapper: defaultMapper}
+	mapper := util.NewCachingRESTMapper(underlying)
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(deployGVK)
+	for i := 0; i < 2; i++ {
+		name, err := util.GetDefinitionName(mapper, u, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "deployments.apps", name)
+
+		gvk, err := util.GetGVKFromDefinition(mapper, common.DefinitionReference{Name: "deployments.apps", Version: "v1"})
+		assert.NoError(t, err)
+		assert.Equal(t, metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, gvk)
+
+		ref, err := util.ConvertWorkloadGVK2Definition(mapper, common.WorkloadGVK{APIVersion: "apps/v1", Kind: "Deployment"})
+		assert.NoError(t, err)
+		assert.Equal(t, "deployments.apps", ref.Name)
+	}
+	// the second lookup does not call the underlying mapper
+	assert.Equal(t, 1, underlying.mappingCalls)
+	assert.Equal(t, 1, underlying.kindsCalls)
+
+	// the failed lookups are not cached and reset the underlying mapper
+	for i := 0; i < 2; i++ {
+		_, err := util.GetGVKFromDefinition(mapper, common.DefinitionReference{Name: "foos.example.com", Version: "v1"})
+		assert.True(t, meta.IsNoMatchError(err))
+	}
+	assert.Equal(t, 3, underlying.kindsCalls)
+	assert.Equal(t, 2, underlying.resets)
+
+	mapper.Reset()
+	assert.Equal(t, 3, underlying.resets)
+	_, err := util.GetDefinitionName(mapper, u, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, underlying.mappingCalls)
+}
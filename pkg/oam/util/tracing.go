@@ -0,0 +1,63 @@
+/*
+Copyright 2026 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the name of the tracer used in this package
+	TracerName = "github.com/oam-dev/kubevela/pkg/oam/util"
+
+	// AttributeDefinitionName is the span attribute of the definition name
+	AttributeDefinitionName = attribute.Key("definition.name")
+	// AttributeDefinitionType is the span attribute of the definition type
+	AttributeDefinitionType = attribute.Key("definition.type")
+	// AttributeDefinitionAutoUpdate is the span attribute recording whether the auto-update is triggered
+	AttributeDefinitionAutoUpdate = attribute.Key("definition.autoUpdate")
+)
+
+// startSpan starts a span with the tracer of this package
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the error if exists and ends the span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceClientCall wraps the client call with a span, it returns directly if the context is already done
+func traceClientCall(ctx context.Context, name string, call func(ctx context.Context) error, attrs ...attribute.KeyValue) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, span := startSpan(ctx, name, attrs...)
+	err := call(ctx)
+	endSpan(span, err)
+	return err
+}
@@ -58,6 +58,11 @@ func (h *ValidatingHandler) Handle(ctx context.Context, req admission.Request) a
 		if err != nil {
 			return admission.Errored(http.StatusBadRequest, err)
 		}
+		if req.Operation == admissionv1.Create {
+			if err := util.ValidateDefinitionNameNotRevisionLike(obj.Name); err != nil {
+				return admission.Denied(err.Error())
+			}
+		}
 		err = ValidateWorkload(h.Client.RESTMapper(), obj)
 		if err != nil {
 			return admission.Denied(err.Error())
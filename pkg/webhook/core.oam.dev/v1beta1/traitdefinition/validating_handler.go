@@ -33,6 +33,7 @@ import (
 	"github.com/oam-dev/kubevela/pkg/appfile"
 	controller "github.com/oam-dev/kubevela/pkg/controller/core.oam.dev"
 	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
 	webhookutils "github.com/oam-dev/kubevela/pkg/webhook/utils"
 )
 
@@ -81,6 +82,11 @@ func (h *ValidatingHandler) Handle(ctx context.Context, req admission.Request) a
 		if err != nil {
 			return admission.Errored(http.StatusBadRequest, err)
 		}
+		if req.Operation == admissionv1.Create {
+			if err := util.ValidateDefinitionNameNotRevisionLike(obj.Name); err != nil {
+				return admission.Denied(err.Error())
+			}
+		}
 		klog.Info("validating ", " name: ", obj.Name, " operation: ", string(req.Operation))
 		for _, validator := range h.Validators {
 			if err := validator.Validate(ctx, *obj); err != nil {
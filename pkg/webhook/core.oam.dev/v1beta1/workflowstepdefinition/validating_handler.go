@@ -29,6 +29,7 @@ import (
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
 	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
 	webhookutils "github.com/oam-dev/kubevela/pkg/webhook/utils"
 )
 
@@ -65,6 +66,11 @@ func (h *ValidatingHandler) Handle(_ context.Context, req admission.Request) adm
 		if err != nil {
 			return admission.Errored(http.StatusBadRequest, err)
 		}
+		if req.Operation == admissionv1.Create {
+			if err := util.ValidateDefinitionNameNotRevisionLike(obj.Name); err != nil {
+				return admission.Denied(err.Error())
+			}
+		}
 
 		if obj.Spec.Version != "" {
 			err = webhookutils.ValidateSemanticVersion(obj.Spec.Version)